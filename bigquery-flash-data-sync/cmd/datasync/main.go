@@ -21,6 +21,7 @@ package main
 
 import (
     "context"
+    "flag"
     "os/user"
     "time"
 
@@ -44,6 +45,9 @@ var (
 
 // main initializes logging, configuration, and starts the sync pipeline.
 func main() {
+    resume := flag.Bool("resume", false, "continue interrupted table syncs from their checkpoints instead of starting over")
+    flag.Parse()
+
     // Initialize logger first
     logger.InitLogger()
     defer logger.Sync()
@@ -77,6 +81,7 @@ func main() {
     if err != nil {
         logger.Logger.Fatal("Failed to load configuration", zap.Error(err))
     }
+    cfg.Resume = *resume
 
     // Log configuration summary
     logConfigSummary(cfg)
@@ -89,6 +94,7 @@ func main() {
     logger.Logger.Info("Starting data sync pipeline",
         zap.Duration("timeout", cfg.SyncTimeout),
         zap.Bool("dry_run", cfg.DryRun),
+        zap.Bool("resume", cfg.Resume),
     )
 
     if err := pipeline.Start(ctx, cfg, logger.Logger); err != nil {
@@ -109,6 +115,7 @@ func logConfigSummary(cfg *model.Config) {
             zap.String("type", db.Type),
             zap.Bool("enabled", db.Enabled),
             zap.Int("tables", len(db.GetEnabledTables())),
+            zap.Bool("discover_tables", db.DiscoverTables),
         )
     }
 
@@ -40,17 +40,36 @@ const (
 	DBMaxIdleConns    = "DB_MAX_IDLE_CONNECTIONS"
 	DBConnMaxLifetime = "DB_CONN_MAX_LIFETIME"
 
+	MaxConcurrentTables   = "MAX_CONCURRENT_TABLES"
+	DBMaxConcurrentTables = "DB_MAX_CONCURRENT_TABLES"
+	ConsistentSnapshot    = "CONSISTENT_SNAPSHOT"
+
 	GCPProjectID = "GCP_PROJECT_ID"
 	BQDatasetID  = "BQ_DATASET_ID"
 
 	SyncTimeout     = "SYNC_TIMEOUT"
 	DateFormat      = "DATE_FORMAT"
 	DefaultBatchSize = "DEFAULT_BATCH_SIZE"
+	LoadJobMaxBytes  = "LOAD_JOB_MAX_BYTES"
 
 	DryRun              = "DRY_RUN"
 	CreateTables        = "AUTO_CREATE_TABLES"
 	TruncateOnSync    = "TRUNCATE_ON_SYNC"
 	MaxRowParseFailures = "MAX_ROW_PARSE_FAILURES"
+
+	RetryMaxAttempts    = "RETRY_MAX_ATTEMPTS"
+	RetryInitialBackoff = "RETRY_INITIAL_BACKOFF"
+	RetryMaxBackoff     = "RETRY_MAX_BACKOFF"
+
+	SyncStateTable      = "SYNC_STATE_TABLE"
+	CDCBatchSize        = "CDC_BATCH_SIZE"
+	SyncCheckpointTable = "SYNC_CHECKPOINT_TABLE"
+	SyncCheckpoints     = "SYNC_CHECKPOINTS"
+	WatermarkOverlap = "WATERMARK_OVERLAP"
+	SchemaPolicy     = "SCHEMA_POLICY"
+	DeleteDetection  = "DELETE_DETECTION"
+	ChangeDetection  = "CHANGE_DETECTION"
+	OnInvalidValue   = "ON_INVALID_VALUE"
 )
 
 // LoadConfig reads all required environment variables and builds database connection strings.
@@ -80,6 +99,7 @@ func LoadConfig(logger *zap.Logger) (*model.Config, error) {
 		logger.Info("Loaded database configuration",
 			zap.String("database", dbName),
 			zap.Int("tables", len(dbConfig.Tables)),
+			zap.Bool("discover_tables", dbConfig.DiscoverTables),
 		)
 	}
 
@@ -89,18 +109,28 @@ func LoadConfig(logger *zap.Logger) (*model.Config, error) {
 
 	maxOpen := parseInt(logger, DBMaxOpenConns, "10", 10)
 	maxIdle := parseInt(logger, DBMaxIdleConns, "10", 10)
-	defaultBatchSize := parseInt(logger, DefaultBatchSize, "1000", 1000)
+	defaultBatchSize := parseInt(logger, DefaultBatchSize, "0", 0)
+	loadJobMaxBytes := parseInt(logger, LoadJobMaxBytes, "1073741824", 1<<30)
 	maxRowParseFailures := parseInt(logger, MaxRowParseFailures, "100", 100)
+	maxConcurrentTables := parseInt(logger, MaxConcurrentTables, "8", 8)
 
 	syncTimeout := parseDuration(logger, SyncTimeout, "10m", 10*time.Minute)
 	connMaxLifetime := parseDuration(logger, DBConnMaxLifetime, "1m", 1*time.Minute)
 
+	retryMaxAttempts := parseInt(logger, RetryMaxAttempts, "3", 3)
+	retryInitialBackoff := parseDuration(logger, RetryInitialBackoff, "1s", 1*time.Second)
+	retryMaxBackoff := parseDuration(logger, RetryMaxBackoff, "30s", 30*time.Second)
+
 	dateFormat := getEnv(DateFormat, "2006-01-02T15:04:05Z07:00")
 
 	dryRun := parseBool(getEnv(DryRun, "false"))
 	createTables := parseBool(getEnv(CreateTables, "true"))
 	truncateOnSync := parseBool(getEnv(TruncateOnSync, "false"))
 
+	stateTable := getEnv(SyncStateTable, "_sync_state")
+	checkpointTable := getEnv(SyncCheckpointTable, "_sync_checkpoints")
+	checkpoints := parseBool(getEnv(SyncCheckpoints, "true"))
+
 	cfg := &model.Config{
 		GCPProjectID:        gcpProjectID,
 		BigQueryDatasetID:   bqDatasetID,
@@ -108,6 +138,7 @@ func LoadConfig(logger *zap.Logger) (*model.Config, error) {
 		SyncTimeout:         syncTimeout,
 		DateFormat:          dateFormat,
 		DefaultBatchSize:    defaultBatchSize,
+		LoadJobMaxBytes:     int64(loadJobMaxBytes),
 		MaxOpenConns:        maxOpen,
 		MaxIdleConns:        maxIdle,
 		ConnMaxLifetime:     connMaxLifetime,
@@ -115,6 +146,13 @@ func LoadConfig(logger *zap.Logger) (*model.Config, error) {
 		CreateTables:        createTables,
 		TruncateOnSync:      truncateOnSync,
 		MaxRowParseFailures: maxRowParseFailures,
+		MaxConcurrentTables: maxConcurrentTables,
+		RetryMaxAttempts:    retryMaxAttempts,
+		RetryInitialBackoff: retryInitialBackoff,
+		RetryMaxBackoff:     retryMaxBackoff,
+		StateTable:          stateTable,
+		CheckpointTable:     checkpointTable,
+		Checkpoints:         checkpoints,
 	}
 
 	logger.Info("Configuration loaded successfully",
@@ -123,6 +161,7 @@ func LoadConfig(logger *zap.Logger) (*model.Config, error) {
 		zap.Int("database_count", len(databases)),
 		zap.Bool("dry_run", cfg.DryRun),
 		zap.Int("max_row_parse_failures", cfg.MaxRowParseFailures),
+		zap.Int("max_concurrent_tables", cfg.MaxConcurrentTables),
 	)
 
 	return cfg, nil
@@ -142,6 +181,8 @@ func loadDatabaseConfig(logger *zap.Logger, dbID string) (*model.DatabaseConfig,
 	user := getEnv(prefix+"DB_USER", "")
 	password := getEnv(prefix+"DB_PASSWORD", "")
 	enabled := parseBool(getEnv(prefix+"ENABLED", "true"))
+	maxConcurrentTables := parseInt(logger, prefix+"DB_MAX_CONCURRENT_TABLES", getEnv(DBMaxConcurrentTables, "4"), 4)
+	consistentSnapshot := parseBool(getEnv(prefix+"CONSISTENT_SNAPSHOT", getEnv(ConsistentSnapshot, "false")))
 
 	if database == "" || user == "" {
 		return nil, fmt.Errorf("missing required config: %sDB_NAME and %sDB_USER are required", prefix, prefix)
@@ -149,41 +190,99 @@ func loadDatabaseConfig(logger *zap.Logger, dbID string) (*model.DatabaseConfig,
 
 	connString := buildConnectionString(logger, dbType, host, port, database, user, password, prefix)
 
+	checkTable := func(table *model.TableConfig) error {
+		if table.IsCDC() && !strings.EqualFold(dbType, "mysql") && !strings.EqualFold(dbType, "postgres") {
+			return fmt.Errorf("table '%s': SYNC_MODE=%s is only supported for mysql and postgres sources", table.Name, model.SyncModeCDC)
+		}
+		return nil
+	}
+
+	discoverTables := strings.TrimSpace(getEnv(prefix+"TABLES", "")) == "*"
 	tables, err := loadTableConfigs(logger, dbID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load table configs: %w", err)
 	}
+	for _, table := range tables {
+		if err := checkTable(table); err != nil {
+			return nil, err
+		}
+	}
+
+	includeTables := parseCommaList(getEnv(prefix+"TABLES_INCLUDE", ""))
+	excludeTables := parseCommaList(getEnv(prefix+"TABLES_EXCLUDE", ""))
+	for _, pattern := range append(append([]string{}, includeTables...), excludeTables...) {
+		if _, err := model.MatchTablePattern(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid table pattern '%s': %w", pattern, err)
+		}
+	}
+	if discoverTables {
+		if !strings.EqualFold(dbType, "mysql") && !strings.EqualFold(dbType, "postgres") {
+			return nil, fmt.Errorf("%sTABLES=* is only supported for mysql and postgres sources", prefix)
+		}
+	} else if len(includeTables) > 0 || len(excludeTables) > 0 {
+		logger.Warn("Table include and exclude patterns are ignored without TABLES=*",
+			zap.String("database", dbID))
+		includeTables, excludeTables = nil, nil
+	}
+	if consistentSnapshot && !strings.EqualFold(dbType, "mysql") && !strings.EqualFold(dbType, "postgres") {
+		return nil, fmt.Errorf("%sCONSISTENT_SNAPSHOT is only supported for mysql and postgres sources", prefix)
+	}
 
 	return &model.DatabaseConfig{
-		Name:             dbID,
-		Type:             dbType,
-		Host:             host,
-		Port:             port,
-		DatabaseName:     database,
-		User:             user,
-		ConnectionString: connString,
-		Tables:           tables,
-		Enabled:          enabled,
+		Name:                dbID,
+		Type:                dbType,
+		Host:                host,
+		Port:                port,
+		DatabaseName:        database,
+		User:                user,
+		ConnectionString:    connString,
+		Tables:              tables,
+		Enabled:             enabled,
+		MaxConcurrentTables: maxConcurrentTables,
+		ConsistentSnapshot:  consistentSnapshot,
+		DiscoverTables:      discoverTables,
+		IncludeTables:       includeTables,
+		ExcludeTables:       excludeTables,
+		DiscoveredTableConfig: func(tableName string) (*model.TableConfig, error) {
+			table, err := loadTableConfig(logger, dbID, tableName)
+			if err != nil {
+				return nil, err
+			}
+			return table, checkTable(table)
+		},
 	}, nil
 }
 
 // loadTableConfigs loads table configurations for a specific database.
-// Tables are specified via {PREFIX}_TABLES environment variable.
+// Tables are specified via {PREFIX}_TABLES environment variable. With {PREFIX}_TABLES=* no tables
+// are loaded here: they are discovered from the source database when the pipeline starts.
 func loadTableConfigs(logger *zap.Logger, dbID string) (map[string]*model.TableConfig, error) {
 	prefix := strings.ToUpper(strings.TrimSpace(dbID)) + "_"
 	tablesStr := getEnv(prefix+"TABLES", "")
 	if tablesStr == "" {
-		return nil, fmt.Errorf("%sTABLES is required (comma-separated list of table names)", prefix)
+		return nil, fmt.Errorf("%sTABLES is required (comma-separated list of table names, or * to discover them)", prefix)
+	}
+	if strings.TrimSpace(tablesStr) == "*" {
+		return map[string]*model.TableConfig{}, nil
 	}
 
 	tableList := parseCommaList(tablesStr)
 	if len(tableList) == 0 {
 		return nil, fmt.Errorf("no valid tables found for database '%s'", dbID)
 	}
+	for _, tableName := range tableList {
+		if tableName == "*" {
+			return nil, fmt.Errorf("%sTABLES=* cannot be combined with table names, use %sTABLES_INCLUDE instead", prefix, prefix)
+		}
+	}
 
 	tables := make(map[string]*model.TableConfig, len(tableList))
 	for _, tableName := range tableList {
-		tables[tableName] = loadTableConfig(logger, dbID, tableName)
+		tableConfig, err := loadTableConfig(logger, dbID, tableName)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config for table '%s': %w", tableName, err)
+		}
+		tables[tableName] = tableConfig
 	}
 
 	return tables, nil
@@ -191,25 +290,226 @@ func loadTableConfigs(logger *zap.Logger, dbID string) (map[string]*model.TableC
 
 // loadTableConfig loads configuration for a specific table.
 // Environment variables are prefixed with {DB_ID}_{TABLE_NAME}_ in uppercase.
-func loadTableConfig(logger *zap.Logger, dbID, tableName string) *model.TableConfig {
+func loadTableConfig(logger *zap.Logger, dbID, tableName string) (*model.TableConfig, error) {
 	prefix := strings.ToUpper(strings.TrimSpace(dbID)) + "_" + strings.ToUpper(strings.TrimSpace(tableName)) + "_"
 
 	targetTable := getEnv(prefix+"TARGET_TABLE", tableName)
 	primaryKey := getEnv(prefix+"PRIMARY_KEY", "id")
 	timestampCol := getEnv(prefix+"TIMESTAMP_COLUMN", "")
 	columnsStr := getEnv(prefix+"COLUMNS", "")
+	customQuery := strings.TrimRight(strings.TrimSpace(getEnv(prefix+"QUERY", "")), "; \t\r\n")
+	where := strings.TrimSpace(getEnv(prefix+"WHERE", ""))
 	batchSize := parseInt(logger, prefix+"BATCH_SIZE", "0", 0)
 	enabled := parseBool(getEnv(prefix+"ENABLED", "true"))
+	syncMode := strings.ToLower(getEnv(prefix+"SYNC_MODE", model.SyncModeFull))
+	overlap := parseDuration(logger, prefix+"WATERMARK_OVERLAP", getEnv(WatermarkOverlap, "0s"), 0)
+
+	truncateOnSync := parseBool(getEnv(TruncateOnSync, "false"))
+	defaultWriteMode := model.WriteModeAppend
+
+	switch syncMode {
+	case model.SyncModeFull:
+		if truncateOnSync {
+			defaultWriteMode = model.WriteModeTruncate
+		}
+	case model.SyncModeIncremental:
+		if timestampCol == "" {
+			return nil, fmt.Errorf("%sSYNC_MODE=incremental requires %sTIMESTAMP_COLUMN", prefix, prefix)
+		}
+		if truncateOnSync {
+			logger.Warn("TRUNCATE_ON_SYNC is ignored for incrementally synced tables",
+				zap.String("database", dbID),
+				zap.String("table", tableName))
+		}
+	case model.SyncModeCDC:
+		// Changes are upserted and deleted on the primary key.
+		defaultWriteMode = model.WriteModeMerge
+	default:
+		return nil, fmt.Errorf("invalid %sSYNC_MODE %q: expected %s, %s or %s",
+			prefix, syncMode, model.SyncModeFull, model.SyncModeIncremental, model.SyncModeCDC)
+	}
+
+	if customQuery != "" {
+		keyword := strings.ToUpper(customQuery)
+		if !strings.HasPrefix(keyword, "SELECT") && !strings.HasPrefix(keyword, "WITH") {
+			return nil, fmt.Errorf("%sQUERY must be a SELECT statement", prefix)
+		}
+	}
+	if (customQuery != "" || where != "") && syncMode == model.SyncModeCDC {
+		// The change log holds changes of the table's rows, not of a query's result.
+		return nil, fmt.Errorf("%sQUERY and %sWHERE cannot be combined with SYNC_MODE=cdc", prefix, prefix)
+	}
+
+	changeDetection := strings.ToLower(getEnv(prefix+"CHANGE_DETECTION", getEnv(ChangeDetection, model.ChangeDetectionNone)))
+	switch changeDetection {
+	case model.ChangeDetectionNone:
+	case model.ChangeDetectionHash:
+		if syncMode == model.SyncModeCDC {
+			return nil, fmt.Errorf("%sCHANGE_DETECTION=hash cannot be combined with SYNC_MODE=cdc", prefix)
+		}
+		// Row hashes are compared with the target rows of the same key.
+		defaultWriteMode = model.WriteModeMerge
+	default:
+		return nil, fmt.Errorf("invalid %sCHANGE_DETECTION %q: expected %s or %s", prefix, changeDetection,
+			model.ChangeDetectionNone, model.ChangeDetectionHash)
+	}
+
+	writeMode := strings.ToLower(getEnv(prefix+"WRITE_MODE", defaultWriteMode))
+	switch writeMode {
+	case model.WriteModeAppend:
+	case model.WriteModeTruncate:
+		if syncMode == model.SyncModeIncremental {
+			return nil, fmt.Errorf("%sWRITE_MODE=truncate cannot be combined with SYNC_MODE=incremental", prefix)
+		}
+	case model.WriteModeMerge, model.WriteModeSCD2:
+		if len(parseCommaList(primaryKey)) == 0 {
+			return nil, fmt.Errorf("%sWRITE_MODE=%s requires %sPRIMARY_KEY", prefix, writeMode, prefix)
+		}
+	case model.WriteModeSnapshot:
+		// Every snapshot is a full copy of the table.
+		if syncMode == model.SyncModeIncremental {
+			return nil, fmt.Errorf("%sWRITE_MODE=snapshot cannot be combined with SYNC_MODE=incremental", prefix)
+		}
+	default:
+		return nil, fmt.Errorf("invalid %sWRITE_MODE %q: expected %s, %s, %s, %s or %s",
+			prefix, writeMode, model.WriteModeAppend, model.WriteModeTruncate, model.WriteModeMerge, model.WriteModeSCD2, model.WriteModeSnapshot)
+	}
+
+	if syncMode == model.SyncModeCDC && writeMode != model.WriteModeMerge {
+		return nil, fmt.Errorf("%sSYNC_MODE=cdc requires WRITE_MODE=merge", prefix)
+	}
+	if changeDetection == model.ChangeDetectionHash && writeMode != model.WriteModeMerge && writeMode != model.WriteModeSCD2 {
+		return nil, fmt.Errorf("%sCHANGE_DETECTION=hash requires WRITE_MODE=merge or scd2", prefix)
+	}
+	cdcBatchSize := parseInt(logger, prefix+"CDC_BATCH_SIZE", getEnv(CDCBatchSize, "10000"), 10000)
+	cdcSlot := getEnv(prefix+"CDC_SLOT", replicationObjectName(dbID, tableName))
+	cdcPublication := getEnv(prefix+"CDC_PUBLICATION", replicationObjectName(dbID, tableName))
+
+	deleteDetection := strings.ToLower(getEnv(prefix+"DELETE_DETECTION", getEnv(DeleteDetection, model.DeleteDetectionNone)))
+	switch deleteDetection {
+	case model.DeleteDetectionNone:
+	case model.DeleteDetectionDelete, model.DeleteDetectionFlag:
+		if len(parseCommaList(primaryKey)) == 0 {
+			return nil, fmt.Errorf("%sDELETE_DETECTION=%s requires %sPRIMARY_KEY", prefix, deleteDetection, prefix)
+		}
+		// CDC tables apply source deletes themselves, truncate syncs replace the whole table and
+		// every snapshot only holds the rows the source had at the time.
+		if syncMode == model.SyncModeCDC || writeMode == model.WriteModeTruncate || writeMode == model.WriteModeSnapshot {
+			logger.Warn("DELETE_DETECTION is ignored for CDC tables, WRITE_MODE=truncate and WRITE_MODE=snapshot",
+				zap.String("database", dbID),
+				zap.String("table", tableName))
+			deleteDetection = model.DeleteDetectionNone
+		}
+	default:
+		return nil, fmt.Errorf("invalid %sDELETE_DETECTION %q: expected %s, %s or %s", prefix, deleteDetection,
+			model.DeleteDetectionNone, model.DeleteDetectionDelete, model.DeleteDetectionFlag)
+	}
+
+	parallelChunks := parseInt(logger, prefix+"PARALLEL_CHUNKS", "1", 1)
+	var splitPoints []int64
+	for _, v := range parseCommaList(getEnv(prefix+"SPLIT_POINTS", "")) {
+		point, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sSPLIT_POINTS value %q: expected integers", prefix, v)
+		}
+		splitPoints = append(splitPoints, point)
+	}
+	if (parallelChunks > 1 || len(splitPoints) > 0) && len(parseCommaList(primaryKey)) != 1 {
+		return nil, fmt.Errorf("%sPARALLEL_CHUNKS and %sSPLIT_POINTS require a single-column %sPRIMARY_KEY", prefix, prefix, prefix)
+	}
+
+	schemaPolicy := strings.ToLower(getEnv(prefix+"SCHEMA_POLICY", getEnv(SchemaPolicy, model.SchemaPolicyEvolve)))
+	switch schemaPolicy {
+	case model.SchemaPolicyFail, model.SchemaPolicyEvolve, model.SchemaPolicyRecreate, model.SchemaPolicyQuarantine:
+	default:
+		return nil, fmt.Errorf("invalid %sSCHEMA_POLICY %q: expected %s, %s, %s or %s", prefix, schemaPolicy,
+			model.SchemaPolicyFail, model.SchemaPolicyEvolve, model.SchemaPolicyRecreate, model.SchemaPolicyQuarantine)
+	}
+
+	onInvalidValue, invalidValueColumns, err := parseInvalidValuePolicy(getEnv(prefix+"ON_INVALID_VALUE", getEnv(OnInvalidValue, model.InvalidValueNull)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %sON_INVALID_VALUE: %w", prefix, err)
+	}
 
 	return &model.TableConfig{
-		Name:            tableName,
-		TargetTable:     targetTable,
-		PrimaryKey:      primaryKey,
-		TimestampColumn: timestampCol,
-		Columns:         parseCommaList(columnsStr),
-		BatchSize:       batchSize,
-		Enabled:         enabled,
+		Name:             tableName,
+		TargetTable:      targetTable,
+		PrimaryKey:       primaryKey,
+		TimestampColumn:  timestampCol,
+		Columns:          parseCommaList(columnsStr),
+		Query:            customQuery,
+		Where:            where,
+		BatchSize:        batchSize,
+		Enabled:          enabled,
+		SyncMode:         syncMode,
+		WatermarkOverlap: overlap,
+		WriteMode:        writeMode,
+		SchemaPolicy:     schemaPolicy,
+		ParallelChunks:   parallelChunks,
+		SplitPoints:      splitPoints,
+		CDCBatchSize:     cdcBatchSize,
+		CDCSlot:          cdcSlot,
+		CDCPublication:   cdcPublication,
+		DeleteDetection:  deleteDetection,
+		ChangeDetection:  changeDetection,
+
+		OnInvalidValue:      onInvalidValue,
+		InvalidValueColumns: invalidValueColumns,
+	}, nil
+}
+
+// parseInvalidValuePolicy parses an invalid value policy setting: a comma-separated list of a
+// table-wide policy and column=policy overrides, e.g. "null,amount=fail".
+func parseInvalidValuePolicy(value string) (string, map[string]string, error) {
+	validate := func(policy string) (string, error) {
+		policy = strings.ToLower(strings.TrimSpace(policy))
+		switch policy {
+		case model.InvalidValueNull, model.InvalidValueSkip, model.InvalidValueFail:
+			return policy, nil
+		}
+		return "", fmt.Errorf("unknown policy %q: expected %s, %s or %s", policy,
+			model.InvalidValueNull, model.InvalidValueSkip, model.InvalidValueFail)
+	}
+
+	tablePolicy := model.InvalidValueNull
+	columns := make(map[string]string)
+	for _, entry := range parseCommaList(value) {
+		column, policy, isColumn := strings.Cut(entry, "=")
+		if !isColumn {
+			p, err := validate(entry)
+			if err != nil {
+				return "", nil, err
+			}
+			tablePolicy = p
+			continue
+		}
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return "", nil, fmt.Errorf("missing column name in %q", entry)
+		}
+		p, err := validate(policy)
+		if err != nil {
+			return "", nil, fmt.Errorf("column %q: %w", column, err)
+		}
+		columns[strings.ToLower(column)] = p
+	}
+	return tablePolicy, columns, nil
+}
+
+// replicationObjectName returns the default name of the PostgreSQL replication slot and publication
+// of a CDC table: datasync_{db}_{table}, lowercased, with every other character replaced by an
+// underscore and cut to the 63 characters PostgreSQL allows.
+func replicationObjectName(dbID, tableName string) string {
+	name := []byte(strings.ToLower("datasync_" + strings.TrimSpace(dbID) + "_" + strings.TrimSpace(tableName)))
+	for i, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	if len(name) > 63 {
+		name = name[:63]
 	}
+	return string(name)
 }
 
 // buildConnectionString creates a database connection string based on type.
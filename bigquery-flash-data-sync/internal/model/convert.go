@@ -0,0 +1,581 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/bigquery"
+	"go.uber.org/zap"
+)
+
+// InvalidValueError reports a source value that cannot be represented in the BigQuery type of its
+// column. Policy is the invalid value policy applied to it: skip or fail.
+type InvalidValueError struct {
+	Column string
+	Type   bigquery.FieldType
+	Reason string
+	Policy string
+}
+
+func (e *InvalidValueError) Error() string {
+	return fmt.Sprintf("column %q: cannot load value as %s: %s", e.Column, e.Type, e.Reason)
+}
+
+// BigQuery's range of DATE, DATETIME and TIMESTAMP values.
+var (
+	minBigQueryTime = time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	maxBigQueryTime = time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// Layouts of the JSON encoding BigQuery load jobs accept for time values. Sources store at most
+// microseconds, which is also BigQuery's precision.
+const (
+	bigQueryDateLayout      = "2006-01-02"
+	bigQueryTimeLayout      = "15:04:05.999999"
+	bigQueryDateTimeLayout  = "2006-01-02 15:04:05.999999"
+	bigQueryTimestampLayout = "2006-01-02T15:04:05.999999Z07:00"
+)
+
+// sourceTimeLayouts are the text formats time values are parsed from when the driver returns
+// them as text, e.g. MySQL TIME columns or PostgreSQL values decoded from a change log.
+var sourceTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	time.DateOnly,
+}
+
+// validDecimal matches the text form of DECIMAL and NUMERIC values.
+var validDecimal = regexp.MustCompile(`^[+-]?([0-9]+\.?[0-9]*|\.[0-9]+)$`)
+
+// RowConverter converts the values scanned from the source into the JSON encoding BigQuery load
+// jobs expect for the type of their column in the target schema, instead of guessing from the Go
+// type the driver returned: DATE, TIME, DATETIME and TIMESTAMP values in the layout of the column
+// type, NUMERIC and BIGNUMERIC values as exact decimal strings, NaN and infinite FLOAT values as
+// the strings BigQuery reads them from, and BYTES values base64-encoded. Values of REPEATED fields
+// are PostgreSQL arrays, whose elements are converted the same way. Values of columns missing from
+// the schema, and of types without a dedicated conversion, are converted by ConvertValue.
+//
+// A RowConverter is safe for concurrent use by the chunks of a table.
+type RowConverter struct {
+	fields     map[string]*bigquery.FieldSchema
+	policy     func(column string) string
+	dateFormat string
+	logger     *zap.Logger
+
+	warned             sync.Map // Columns a NULL replacement was already logged for
+	warnedNullElements sync.Map // Array columns dropped NULL elements were already logged for
+}
+
+// NewRowConverter creates a converter for the columns of schema. policy returns the invalid value
+// policy of a column.
+func NewRowConverter(schema bigquery.Schema, policy func(column string) string, dateFormat string, logger *zap.Logger) *RowConverter {
+	fields := make(map[string]*bigquery.FieldSchema, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = field
+	}
+	return &RowConverter{fields: fields, policy: policy, dateFormat: dateFormat, logger: logger}
+}
+
+// Convert converts the value of a column. A value that cannot be represented in the column's type
+// is replaced by NULL or returned as an *InvalidValueError, according to the column's policy.
+func (c *RowConverter) Convert(column string, val any) (any, error) {
+	if val == nil {
+		return nil, nil
+	}
+	field, ok := c.fields[column]
+	if !ok {
+		return ConvertValue(val, c.dateFormat, c.logger), nil
+	}
+
+	converted, reason := c.fieldValue(field, val)
+	if reason == "" {
+		return converted, nil
+	}
+	return c.invalid(field, reason)
+}
+
+// fieldValue converts a non-NULL value for a field, as an array if the field is REPEATED.
+func (c *RowConverter) fieldValue(field *bigquery.FieldSchema, val any) (any, string) {
+	if field.Repeated {
+		return c.arrayValue(field, val)
+	}
+	return c.scalarValue(field, val)
+}
+
+// scalarValue converts a single value for the type of a field. It returns the reason a value that
+// cannot be represented is invalid.
+func (c *RowConverter) scalarValue(field *bigquery.FieldSchema, val any) (any, string) {
+	switch field.Type {
+	case bigquery.StringFieldType:
+		return stringValue(val, c.dateFormat, c.logger), ""
+	case bigquery.IntegerFieldType:
+		return integerValue(val)
+	case bigquery.FloatFieldType:
+		return floatValue(val)
+	case bigquery.NumericFieldType, bigquery.BigNumericFieldType:
+		return decimalValue(val, field)
+	case bigquery.BooleanFieldType:
+		return booleanValue(val)
+	case bigquery.DateFieldType:
+		return timeValue(val, bigQueryDateLayout, false)
+	case bigquery.DateTimeFieldType:
+		return timeValue(val, bigQueryDateTimeLayout, false)
+	case bigquery.TimestampFieldType:
+		return timeValue(val, bigQueryTimestampLayout, true)
+	case bigquery.TimeFieldType:
+		return timeOfDayValue(val)
+	case bigquery.BytesFieldType:
+		return bytesValue(val)
+	case bigquery.GeographyFieldType:
+		return geographyValue(val)
+	case bigquery.RecordFieldType:
+		return c.recordValue(field, val)
+	default:
+		return ConvertValue(val, c.dateFormat, c.logger), ""
+	}
+}
+
+// arrayValue converts a PostgreSQL array, read in its text form, into a JSON array of its elements
+// converted for the type of a REPEATED field. BigQuery arrays cannot hold NULL, so NULL elements
+// are dropped with a warning.
+func (c *RowConverter) arrayValue(field *bigquery.FieldSchema, val any) (any, string) {
+	var text string
+	switch v := val.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return nil, fmt.Sprintf("unsupported array value of type %T", val)
+	}
+	elements, err := parsePostgresArray(text)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	out := make([]any, 0, len(elements))
+	droppedNull := false
+	for _, element := range elements {
+		if element == nil {
+			droppedNull = true
+			continue
+		}
+		if field.Type == bigquery.BytesFieldType {
+			decoded, ok := byteaText(element.(string))
+			if !ok {
+				return nil, fmt.Sprintf("invalid bytea element %q", element)
+			}
+			element = decoded
+		}
+		converted, reason := c.scalarValue(field, element)
+		if reason != "" {
+			return nil, "array element " + reason
+		}
+		out = append(out, converted)
+	}
+
+	if droppedNull {
+		if _, warned := c.warnedNullElements.LoadOrStore(field.Name, true); !warned {
+			c.logger.Warn("Array contains NULL elements, which BigQuery arrays cannot hold, dropping them (logged once per column)",
+				zap.String("column", field.Name))
+		}
+	}
+	return out, ""
+}
+
+// recordValue converts a PostgreSQL composite value, read in its text form, into a JSON object of
+// its attributes converted for the nested fields of a RECORD field, which are in attribute order.
+func (c *RowConverter) recordValue(field *bigquery.FieldSchema, val any) (any, string) {
+	var text string
+	switch v := val.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	default:
+		return nil, fmt.Sprintf("unsupported record value of type %T", val)
+	}
+	attributes, err := parsePostgresRecord(text)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if len(attributes) != len(field.Schema) {
+		return nil, fmt.Sprintf("record has %d attributes, expected %d", len(attributes), len(field.Schema))
+	}
+
+	out := make(map[string]any, len(attributes))
+	for i, nested := range field.Schema {
+		attribute := attributes[i]
+		if attribute == nil {
+			out[nested.Name] = nil
+			continue
+		}
+		if nested.Type == bigquery.BytesFieldType && !nested.Repeated {
+			decoded, ok := byteaText(attribute.(string))
+			if !ok {
+				return nil, fmt.Sprintf("field %s: invalid bytea %q", nested.Name, attribute)
+			}
+			attribute = decoded
+		}
+		converted, reason := c.fieldValue(nested, attribute)
+		if reason != "" {
+			return nil, fmt.Sprintf("field %s: %s", nested.Name, reason)
+		}
+		out[nested.Name] = converted
+	}
+	return out, ""
+}
+
+// byteaText decodes a bytea in its hex text form, e.g. \x0a0b, as nested in arrays and records.
+func byteaText(text string) ([]byte, bool) {
+	decoded, err := hex.DecodeString(strings.TrimPrefix(text, `\x`))
+	return decoded, err == nil
+}
+
+// invalid applies the invalid value policy of a field to one of its values.
+func (c *RowConverter) invalid(field *bigquery.FieldSchema, reason string) (any, error) {
+	policy := InvalidValueNull
+	if c.policy != nil {
+		policy = c.policy(field.Name)
+	}
+	if policy == InvalidValueNull && field.Required {
+		policy = InvalidValueSkip
+	}
+	if policy != InvalidValueNull {
+		return nil, &InvalidValueError{Column: field.Name, Type: field.Type, Reason: reason, Policy: policy}
+	}
+
+	if _, warned := c.warned.LoadOrStore(field.Name, true); !warned {
+		c.logger.Warn("Value cannot be represented in its column type, loading NULL (logged once per column)",
+			zap.String("column", field.Name),
+			zap.String("type", string(field.Type)),
+			zap.String("reason", reason))
+	}
+	return nil, nil
+}
+
+// stringValue converts a value of a STRING column, formatting numbers and booleans as text.
+func stringValue(val any, dateFormat string, logger *zap.Logger) any {
+	switch v := val.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case int32, int16, int8, int, uint32, uint16, uint8, uint:
+		return fmt.Sprintf("%d", v)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return ConvertValue(val, dateFormat, logger)
+}
+
+// integerValue converts a value of an INTEGER column.
+func integerValue(val any) (any, string) {
+	switch v := val.(type) {
+	case int64:
+		return v, ""
+	case int32:
+		return int64(v), ""
+	case int16:
+		return int64(v), ""
+	case int8:
+		return int64(v), ""
+	case int:
+		return int64(v), ""
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Sprintf("%d exceeds the INT64 range", v)
+		}
+		return int64(v), ""
+	case uint32:
+		return int64(v), ""
+	case uint16:
+		return int64(v), ""
+	case uint8:
+		return int64(v), ""
+	case uint:
+		return integerValue(uint64(v))
+	case bool:
+		if v {
+			return int64(1), ""
+		}
+		return int64(0), ""
+	case float64:
+		if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+			return nil, fmt.Sprintf("%v is not an INT64 value", v)
+		}
+		return int64(v), ""
+	case float32:
+		return integerValue(float64(v))
+	case []byte:
+		// The MySQL driver returns every integer column as a Go integer, except BIT(n) columns,
+		// which arrive as big-endian bytes.
+		return bitValue(v)
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return nil, fmt.Sprintf("%q is not an INT64 value", v)
+		}
+		return i, ""
+	}
+	return nil, fmt.Sprintf("unsupported value of type %T", val)
+}
+
+// bitValue decodes a MySQL BIT(n) value. BIT(64) values above the INT64 range keep their bit
+// pattern as a negative integer, as the binary log decodes them.
+func bitValue(b []byte) (any, string) {
+	if len(b) > 8 {
+		return nil, fmt.Sprintf("BIT value of %d bytes exceeds the INT64 range", len(b))
+	}
+	var u uint64
+	for _, c := range b {
+		u = u<<8 | uint64(c)
+	}
+	return int64(u), ""
+}
+
+// bytesValue converts a value of a BYTES column into base64, the encoding BigQuery reads BYTES
+// from in JSON. Binary strings are encoded as they are, without UTF-8 sanitization.
+func bytesValue(val any) (any, string) {
+	switch v := val.(type) {
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v), ""
+	case string:
+		return base64.StdEncoding.EncodeToString([]byte(v)), ""
+	}
+	return nil, fmt.Sprintf("unsupported value of type %T", val)
+}
+
+// floatValue converts a value of a FLOAT column. NaN and infinite values are encoded as the
+// strings BigQuery reads them from, as JSON numbers cannot express them.
+func floatValue(val any) (any, string) {
+	var f float64
+	switch v := val.(type) {
+	case float64:
+		f = v
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			f = float64(v)
+			break
+		}
+		// Keep the shortest decimal form of the float32, not the float64 it widens to.
+		return json.Number(strconv.FormatFloat(float64(v), 'g', -1, 32)), ""
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), ""
+	case int32, int16, int8, int, uint32, uint16, uint8:
+		return json.Number(fmt.Sprintf("%d", v)), ""
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), ""
+	case []byte:
+		return floatValue(string(v))
+	case string:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Sprintf("%q is not a FLOAT64 value", v)
+		}
+		f = parsed
+	default:
+		return nil, fmt.Sprintf("unsupported value of type %T", val)
+	}
+
+	switch {
+	case math.IsNaN(f):
+		return "NaN", ""
+	case math.IsInf(f, 1):
+		return "Infinity", ""
+	case math.IsInf(f, -1):
+		return "-Infinity", ""
+	}
+	return f, ""
+}
+
+// decimalValue converts a value of a NUMERIC or BIGNUMERIC column into an exact decimal string.
+// Values with more integer or fractional digits than the column holds are invalid rather than
+// rounded.
+func decimalValue(val any, field *bigquery.FieldSchema) (any, string) {
+	var text string
+	switch v := val.(type) {
+	case []byte:
+		text = string(v)
+	case string:
+		text = v
+	case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+		text = fmt.Sprintf("%d", v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Sprintf("%v is not a decimal value", v)
+		}
+		text = strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return nil, fmt.Sprintf("%v is not a decimal value", v)
+		}
+		text = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	default:
+		return nil, fmt.Sprintf("unsupported value of type %T", val)
+	}
+
+	text = strings.TrimSpace(text)
+	if !validDecimal.MatchString(text) {
+		return nil, fmt.Sprintf("%q is not a decimal value", text)
+	}
+
+	sign := ""
+	digits := text
+	if digits[0] == '-' || digits[0] == '+' {
+		if digits[0] == '-' {
+			sign = "-"
+		}
+		digits = digits[1:]
+	}
+	integer, fraction, _ := strings.Cut(digits, ".")
+	integer = strings.TrimLeft(integer, "0")
+	fraction = strings.TrimRight(fraction, "0")
+
+	maxInteger, maxScale := int64(29), int64(9)
+	if field.Type == bigquery.BigNumericFieldType {
+		maxInteger, maxScale = 38, 38
+	}
+	if field.Precision > 0 {
+		maxInteger, maxScale = field.Precision-field.Scale, field.Scale
+	}
+	if int64(len(integer)) > maxInteger || int64(len(fraction)) > maxScale {
+		return nil, fmt.Sprintf("%s does not fit %s%s", text, field.Type, TypeParameters(field))
+	}
+
+	if integer == "" {
+		integer = "0"
+	}
+	if integer == "0" && fraction == "" {
+		sign = ""
+	}
+	if fraction != "" {
+		return sign + integer + "." + fraction, ""
+	}
+	return sign + integer, ""
+}
+
+// booleanValue converts a value of a BOOLEAN column.
+func booleanValue(val any) (any, string) {
+	switch v := val.(type) {
+	case bool:
+		return v, ""
+	case int64:
+		if v == 0 || v == 1 {
+			return v == 1, ""
+		}
+	case []byte:
+		// A MySQL BIT(1) value is a single 0 or 1 byte.
+		if len(v) == 1 && v[0] <= 1 {
+			return v[0] == 1, ""
+		}
+		return booleanValue(string(v))
+	case string:
+		if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+			return b, ""
+		}
+	}
+	return nil, fmt.Sprintf("%v is not a boolean value", val)
+}
+
+// timeValue converts a value of a DATE, DATETIME or TIMESTAMP column into the layout of the column
+// type. TIMESTAMP values (utc) are converted to UTC; DATE and DATETIME values keep their wall clock.
+func timeValue(val any, layout string, utc bool) (any, string) {
+	var t time.Time
+	switch v := val.(type) {
+	case time.Time:
+		t = v
+	case []byte:
+		return timeValue(string(v), layout, utc)
+	case string:
+		parsed, ok := parseSourceTime(v)
+		if !ok {
+			return nil, fmt.Sprintf("%q is not a date or time value", v)
+		}
+		t = parsed
+	default:
+		return nil, fmt.Sprintf("unsupported value of type %T", val)
+	}
+
+	if t.IsZero() {
+		return nil, "zero date"
+	}
+	if utc {
+		t = t.UTC()
+	}
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if wall.Before(minBigQueryTime) || !wall.Before(maxBigQueryTime) {
+		return nil, fmt.Sprintf("%s is outside the range of years 1 to 9999", t.Format(time.RFC3339))
+	}
+	return t.Truncate(time.Microsecond).Format(layout), ""
+}
+
+// timeOfDayValue converts a value of a TIME column. MySQL TIME values are durations that may be
+// negative or exceed 24 hours, which a BigQuery TIME cannot hold; a time zone offset of a
+// PostgreSQL time with time zone is dropped.
+func timeOfDayValue(val any) (any, string) {
+	switch v := val.(type) {
+	case time.Time:
+		return v.Truncate(time.Microsecond).Format(bigQueryTimeLayout), ""
+	case []byte:
+		return timeOfDayValue(string(v))
+	case string:
+		text := strings.TrimSpace(v)
+		if i := strings.IndexAny(text, "+-Z"); i > 0 {
+			text = text[:i]
+		}
+		for _, layout := range []string{"15:04:05.999999999", "15:04"} {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t.Truncate(time.Microsecond).Format(bigQueryTimeLayout), ""
+			}
+		}
+		return nil, fmt.Sprintf("%q is not a time of day", v)
+	}
+	return nil, fmt.Sprintf("unsupported value of type %T", val)
+}
+
+// parseSourceTime parses a date or time value in one of the text formats of the sources.
+func parseSourceTime(text string) (time.Time, bool) {
+	text = strings.TrimSpace(text)
+	if !utf8.ValidString(text) {
+		return time.Time{}, false
+	}
+	for _, layout := range sourceTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
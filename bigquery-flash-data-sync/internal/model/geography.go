@@ -0,0 +1,403 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// wgs84SRID is the spatial reference system of BigQuery GEOGRAPHY values.
+const wgs84SRID = 4326
+
+// WKB geometry types.
+const (
+	wkbPoint              = 1
+	wkbLineString         = 2
+	wkbPolygon            = 3
+	wkbMultiPoint         = 4
+	wkbMultiLineString    = 5
+	wkbMultiPolygon       = 6
+	wkbGeometryCollection = 7
+)
+
+// EWKB flags PostGIS sets in the geometry type.
+const (
+	ewkbZ    = 0x80000000
+	ewkbM    = 0x40000000
+	ewkbSRID = 0x20000000
+)
+
+// geographyValue converts a value of a GEOGRAPHY column into WKT, which BigQuery reads GEOGRAPHY
+// from. Values are read in the format of their source:
+//
+//   - MySQL spatial values: a 4-byte little-endian SRID followed by WKB
+//   - PostGIS geometry and geography values: hex-encoded EWKB
+//   - PostgreSQL geometric types: their text form, e.g. (1,2) or ((0,0),(1,0),(1,1))
+//
+// Only WGS84 coordinates can be loaded: values of another SRID than 4326 are invalid, and so are
+// coordinates outside the longitude and latitude range, e.g. of data in a projected system whose
+// SRID was left undefined (0). Z and M coordinates are dropped.
+func geographyValue(val any) (any, string) {
+	var data []byte
+	switch v := val.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return nil, fmt.Sprintf("unsupported value of type %T", val)
+	}
+
+	var wkt string
+	var err error
+	text := strings.TrimSpace(string(data))
+	switch {
+	case len(data) >= 9 && data[4] <= 1:
+		// The byte after the SRID is the WKB byte order marker (0 or 1), which no text starts with.
+		srid := binary.LittleEndian.Uint32(data[:4])
+		if srid != 0 && srid != wgs84SRID {
+			return nil, fmt.Sprintf("SRID %d is not WGS84 (%d)", srid, wgs84SRID)
+		}
+		wkt, err = wkbToWKT(data[4:])
+	case isHex(text):
+		var ewkb []byte
+		if ewkb, err = hex.DecodeString(text); err == nil {
+			wkt, err = wkbToWKT(ewkb)
+		}
+	case strings.HasPrefix(text, "(") || strings.HasPrefix(text, "["):
+		wkt, err = pgGeometricToWKT(text)
+	default:
+		// Already WKT or GeoJSON, e.g. selected with ST_AsText by a custom QUERY.
+		return text, ""
+	}
+	if err != nil {
+		return nil, err.Error()
+	}
+	return wkt, ""
+}
+
+// isHex reports whether s is a non-empty even-length hexadecimal string.
+func isHex(s string) bool {
+	if s == "" || len(s)%2 != 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// errTruncatedWKB is returned for WKB that ends inside a geometry.
+var errTruncatedWKB = errors.New("malformed WKB: unexpected end of data")
+
+// wkbReader decodes a WKB or EWKB geometry into WKT.
+type wkbReader struct {
+	data []byte
+	pos  int
+}
+
+// wkbToWKT decodes a WKB or PostGIS EWKB geometry into WKT.
+func wkbToWKT(data []byte) (string, error) {
+	r := &wkbReader{data: data}
+	var b strings.Builder
+	if err := r.geometry(&b, true); err != nil {
+		return "", err
+	}
+	if r.pos != len(r.data) {
+		return "", fmt.Errorf("malformed WKB: %d trailing bytes", len(r.data)-r.pos)
+	}
+	return b.String(), nil
+}
+
+// geometry decodes one geometry, including its type name. Nested geometries of collections are
+// decoded with top set to false.
+func (r *wkbReader) geometry(b *strings.Builder, top bool) error {
+	if r.pos >= len(r.data) {
+		return errTruncatedWKB
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if r.data[r.pos] == 0 {
+		order = binary.BigEndian
+	}
+	r.pos++
+
+	typ, err := r.uint32(order)
+	if err != nil {
+		return err
+	}
+	dims := 2
+	if typ&ewkbZ != 0 {
+		dims++
+	}
+	if typ&ewkbM != 0 {
+		dims++
+	}
+	if typ&ewkbSRID != 0 {
+		srid, err := r.uint32(order)
+		if err != nil {
+			return err
+		}
+		if top && srid != 0 && srid != wgs84SRID {
+			return fmt.Errorf("SRID %d is not WGS84 (%d)", srid, wgs84SRID)
+		}
+	}
+	typ &^= ewkbZ | ewkbM | ewkbSRID
+	// ISO WKB encodes Z, M and ZM geometries as type + 1000, 2000 and 3000.
+	switch typ / 1000 {
+	case 1, 2:
+		dims++
+	case 3:
+		dims += 2
+	}
+	typ %= 1000
+
+	switch typ {
+	case wkbPoint:
+		b.WriteString("POINT")
+		x, y, err := r.coordinate(order, dims)
+		if err != nil {
+			return err
+		}
+		if math.IsNaN(x) && math.IsNaN(y) {
+			b.WriteString(" EMPTY")
+			return nil
+		}
+		b.WriteByte('(')
+		writeCoordinate(b, x, y)
+		b.WriteByte(')')
+		return checkCoordinate(x, y)
+	case wkbLineString:
+		b.WriteString("LINESTRING")
+		return r.points(b, order, dims)
+	case wkbPolygon:
+		b.WriteString("POLYGON")
+		return r.rings(b, order, dims)
+	case wkbMultiPoint, wkbMultiLineString, wkbMultiPolygon, wkbGeometryCollection:
+		b.WriteString([...]string{wkbMultiPoint: "MULTIPOINT", wkbMultiLineString: "MULTILINESTRING",
+			wkbMultiPolygon: "MULTIPOLYGON", wkbGeometryCollection: "GEOMETRYCOLLECTION"}[typ])
+		n, err := r.count(order)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			b.WriteString(" EMPTY")
+			return nil
+		}
+		b.WriteByte('(')
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			// Members of a collection are written with their type name, which WKT omits
+			// for the members of the typed collections.
+			var member strings.Builder
+			if err := r.geometry(&member, false); err != nil {
+				return err
+			}
+			text := member.String()
+			if typ != wkbGeometryCollection {
+				text = strings.TrimLeft(text, "ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+				if strings.HasPrefix(text, " EMPTY") {
+					text = "EMPTY"
+				}
+			}
+			b.WriteString(text)
+		}
+		b.WriteByte(')')
+		return nil
+	default:
+		return fmt.Errorf("unsupported WKB geometry type %d", typ)
+	}
+}
+
+// points decodes a point list, e.g. of a LINESTRING, as (x y, x y).
+func (r *wkbReader) points(b *strings.Builder, order binary.ByteOrder, dims int) error {
+	n, err := r.count(order)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		b.WriteString(" EMPTY")
+		return nil
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		x, y, err := r.coordinate(order, dims)
+		if err != nil {
+			return err
+		}
+		if err := checkCoordinate(x, y); err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeCoordinate(b, x, y)
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// rings decodes the rings of a POLYGON as ((x y, ...), (x y, ...)).
+func (r *wkbReader) rings(b *strings.Builder, order binary.ByteOrder, dims int) error {
+	n, err := r.count(order)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		b.WriteString(" EMPTY")
+		return nil
+	}
+	b.WriteByte('(')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		if err := r.points(b, order, dims); err != nil {
+			return err
+		}
+	}
+	b.WriteByte(')')
+	return nil
+}
+
+// coordinate decodes one coordinate of dims values and returns its X and Y.
+func (r *wkbReader) coordinate(order binary.ByteOrder, dims int) (float64, float64, error) {
+	if r.pos+8*dims > len(r.data) {
+		return 0, 0, errTruncatedWKB
+	}
+	x := math.Float64frombits(order.Uint64(r.data[r.pos:]))
+	y := math.Float64frombits(order.Uint64(r.data[r.pos+8:]))
+	r.pos += 8 * dims
+	return x, y, nil
+}
+
+// count decodes the number of elements of a list, bounded by the remaining data.
+func (r *wkbReader) count(order binary.ByteOrder) (int, error) {
+	n, err := r.uint32(order)
+	if err != nil {
+		return 0, err
+	}
+	if int(n) > len(r.data)-r.pos {
+		return 0, fmt.Errorf("malformed WKB: element count %d exceeds the data", n)
+	}
+	return int(n), nil
+}
+
+func (r *wkbReader) uint32(order binary.ByteOrder) (uint32, error) {
+	if r.pos+4 > len(r.data) {
+		return 0, errTruncatedWKB
+	}
+	v := order.Uint32(r.data[r.pos:])
+	r.pos += 4
+	return v, nil
+}
+
+// pgGeometricToWKT converts the text form of a PostgreSQL point, lseg, box, path or polygon into
+// WKT. Open paths and line segments become LINESTRINGs; boxes, closed paths and polygons become
+// POLYGONs.
+func pgGeometricToWKT(text string) (string, error) {
+	fields := strings.FieldsFunc(text, func(c rune) bool {
+		return c == '(' || c == ')' || c == '[' || c == ']' || c == ','
+	})
+	if len(fields) == 0 || len(fields)%2 != 0 {
+		return "", fmt.Errorf("malformed geometric value %q", text)
+	}
+	coords := make([]float64, len(fields))
+	for i, field := range fields {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return "", fmt.Errorf("malformed geometric value %q", text)
+		}
+		coords[i] = f
+	}
+	for i := 0; i < len(coords); i += 2 {
+		if err := checkCoordinate(coords[i], coords[i+1]); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	switch {
+	case strings.HasPrefix(text, "["):
+		// lseg or open path
+		if len(coords) < 4 {
+			return "", fmt.Errorf("malformed geometric value %q", text)
+		}
+		b.WriteString("LINESTRING")
+		writeCoordinates(&b, coords, false)
+	case strings.HasPrefix(text, "(("):
+		// polygon or closed path
+		if len(coords) < 6 {
+			return "", fmt.Errorf("polygon %q has fewer than 3 points", text)
+		}
+		b.WriteString("POLYGON(")
+		writeCoordinates(&b, coords, true)
+		b.WriteByte(')')
+	case len(coords) == 2:
+		b.WriteString("POINT")
+		writeCoordinates(&b, coords, false)
+	case len(coords) == 4:
+		// box, given by two opposite corners
+		x1, y1, x2, y2 := coords[0], coords[1], coords[2], coords[3]
+		b.WriteString("POLYGON(")
+		writeCoordinates(&b, []float64{x1, y1, x2, y1, x2, y2, x1, y2}, true)
+		b.WriteByte(')')
+	default:
+		return "", fmt.Errorf("malformed geometric value %q", text)
+	}
+	return b.String(), nil
+}
+
+// writeCoordinates writes a parenthesized coordinate list, closing it into a ring if ring is set.
+func writeCoordinates(b *strings.Builder, coords []float64, ring bool) {
+	n := len(coords)
+	if ring && (coords[0] != coords[n-2] || coords[1] != coords[n-1]) {
+		coords = append(coords[:n:n], coords[0], coords[1])
+	}
+	b.WriteByte('(')
+	for i := 0; i < len(coords); i += 2 {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeCoordinate(b, coords[i], coords[i+1])
+	}
+	b.WriteByte(')')
+}
+
+func writeCoordinate(b *strings.Builder, x, y float64) {
+	b.WriteString(strconv.FormatFloat(x, 'f', -1, 64))
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(y, 'f', -1, 64))
+}
+
+// checkCoordinate rejects coordinates outside the WGS84 longitude and latitude range.
+func checkCoordinate(x, y float64) error {
+	if !(x >= -180 && x <= 180 && y >= -90 && y <= 90) {
+		return fmt.Errorf("coordinate (%v %v) is outside the WGS84 longitude/latitude range", x, y)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+)
+
+// wkb encodes a WKB geometry of the given type in the byte order. parts are the uint32 counts,
+// float64 coordinates and []byte nested geometries that follow the type, in order.
+func wkb(order binary.AppendByteOrder, typ uint32, parts ...any) []byte {
+	b := []byte{1}
+	if order == binary.BigEndian {
+		b[0] = 0
+	}
+	b = order.AppendUint32(b, typ)
+	for _, part := range parts {
+		switch p := part.(type) {
+		case int:
+			b = order.AppendUint32(b, uint32(p))
+		case float64:
+			b = order.AppendUint64(b, math.Float64bits(p))
+		case []byte:
+			b = append(b, p...)
+		}
+	}
+	return b
+}
+
+func TestWKBToWKT(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    string
+		wantErr string
+	}{
+		{name: "little-endian point", data: wkb(le, 1, 1.0, 2.0), want: "POINT(1 2)"},
+		{name: "big-endian point", data: wkb(be, 1, -122.5, 37.25), want: "POINT(-122.5 37.25)"},
+		{name: "linestring", data: wkb(le, 2, 2, 0.0, 0.0, 1.5, 1.5), want: "LINESTRING(0 0, 1.5 1.5)"},
+		{
+			name: "polygon with hole",
+			data: wkb(be, 3, 2,
+				4, 0.0, 0.0, 4.0, 0.0, 4.0, 4.0, 0.0, 0.0,
+				4, 1.0, 1.0, 2.0, 1.0, 2.0, 2.0, 1.0, 1.0),
+			want: "POLYGON((0 0, 4 0, 4 4, 0 0), (1 1, 2 1, 2 2, 1 1))",
+		},
+		{
+			name: "multipoint",
+			data: wkb(le, 4, 2, wkb(le, 1, 1.0, 2.0), wkb(be, 1, 3.0, 4.0)),
+			want: "MULTIPOINT((1 2), (3 4))",
+		},
+		{
+			name: "multilinestring",
+			data: wkb(le, 5, 1, wkb(le, 2, 2, 0.0, 0.0, 1.0, 1.0)),
+			want: "MULTILINESTRING((0 0, 1 1))",
+		},
+		{
+			name: "multipolygon",
+			data: wkb(le, 6, 1, wkb(le, 3, 1, 4, 0.0, 0.0, 1.0, 0.0, 1.0, 1.0, 0.0, 0.0)),
+			want: "MULTIPOLYGON(((0 0, 1 0, 1 1, 0 0)))",
+		},
+		{
+			name: "geometry collection",
+			data: wkb(le, 7, 2, wkb(le, 1, 1.0, 2.0), wkb(le, 2, 2, 0.0, 0.0, 1.0, 1.0)),
+			want: "GEOMETRYCOLLECTION(POINT(1 2), LINESTRING(0 0, 1 1))",
+		},
+		{name: "EWKB Z point", data: wkb(le, ewkbZ|1, 1.0, 2.0, 30.0), want: "POINT(1 2)"},
+		{name: "EWKB M point", data: wkb(be, ewkbM|1, 1.0, 2.0, 7.0), want: "POINT(1 2)"},
+		{name: "EWKB ZM linestring", data: wkb(le, ewkbZ|ewkbM|2, 2, 0.0, 0.0, 9.0, 9.0, 1.0, 1.0, 9.0, 9.0), want: "LINESTRING(0 0, 1 1)"},
+		{name: "ISO Z point", data: wkb(le, 1001, 1.0, 2.0, 30.0), want: "POINT(1 2)"},
+		{name: "ISO M linestring", data: wkb(be, 2002, 2, 0.0, 0.0, 5.0, 1.0, 1.0, 6.0), want: "LINESTRING(0 0, 1 1)"},
+		{name: "ISO ZM point", data: wkb(le, 3001, 1.0, 2.0, 30.0, 7.0), want: "POINT(1 2)"},
+		{
+			name: "ISO Z members of a multipoint",
+			data: wkb(le, 1004, 2, wkb(le, 1001, 1.0, 2.0, 3.0), wkb(le, 1001, 4.0, 5.0, 6.0)),
+			want: "MULTIPOINT((1 2), (4 5))",
+		},
+		{name: "EWKB SRID 4326", data: wkb(le, ewkbSRID|1, 4326, 1.0, 2.0), want: "POINT(1 2)"},
+		{name: "EWKB undefined SRID", data: wkb(le, ewkbSRID|1, 0, 1.0, 2.0), want: "POINT(1 2)"},
+		{name: "EWKB projected SRID", data: wkb(be, ewkbSRID|1, 3857, 1.0, 2.0), wantErr: "SRID 3857 is not WGS84 (4326)"},
+		{name: "empty point", data: wkb(le, 1, math.NaN(), math.NaN()), want: "POINT EMPTY"},
+		{name: "empty linestring", data: wkb(le, 2, 0), want: "LINESTRING EMPTY"},
+		{name: "empty polygon", data: wkb(be, 3, 0), want: "POLYGON EMPTY"},
+		{name: "empty collection", data: wkb(le, 7, 0), want: "GEOMETRYCOLLECTION EMPTY"},
+		{
+			name: "multipoint with empty member",
+			data: wkb(le, 4, 2, wkb(le, 1, 1.0, 2.0), wkb(le, 1, math.NaN(), math.NaN())),
+			want: "MULTIPOINT((1 2), EMPTY)",
+		},
+		{name: "longitude out of range", data: wkb(le, 1, 200.0, 0.0), wantErr: "outside the WGS84"},
+		{name: "latitude out of range", data: wkb(le, 2, 2, 0.0, 0.0, 0.0, 91.0), wantErr: "outside the WGS84"},
+		{name: "unsupported type", data: wkb(le, 17, 1.0, 2.0), wantErr: "unsupported WKB geometry type 17"},
+		{name: "truncated coordinate", data: wkb(le, 1, 1.0)[:13], wantErr: errTruncatedWKB.Error()},
+		{name: "truncated type", data: []byte{1, 1, 0}, wantErr: errTruncatedWKB.Error()},
+		{name: "empty data", data: nil, wantErr: errTruncatedWKB.Error()},
+		{name: "count exceeds data", data: wkb(le, 2, 1000, 0.0, 0.0), wantErr: "element count 1000 exceeds the data"},
+		{name: "trailing bytes", data: append(wkb(le, 1, 1.0, 2.0), 0), wantErr: "1 trailing bytes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := wkbToWKT(tt.data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("WKT = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPgGeometricToWKT(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{name: "point", text: "(1.5,-2)", want: "POINT(1.5 -2)"},
+		{name: "lseg", text: "[(0,0),(1,1)]", want: "LINESTRING(0 0, 1 1)"},
+		{name: "open path", text: "[(0,0),(1,1),(2,0)]", want: "LINESTRING(0 0, 1 1, 2 0)"},
+		{name: "polygon is closed", text: "((0,0),(1,0),(1,1))", want: "POLYGON((0 0, 1 0, 1 1, 0 0))"},
+		{name: "closed path already closed", text: "((0,0),(1,0),(1,1),(0,0))", want: "POLYGON((0 0, 1 0, 1 1, 0 0))"},
+		{name: "box", text: "(1,1),(0,0)", want: "POLYGON((1 1, 0 1, 0 0, 1 0, 1 1))"},
+		{name: "polygon with two points", text: "((0,0),(1,1))", wantErr: "fewer than 3 points"},
+		{name: "odd number of values", text: "(1)", wantErr: "malformed geometric value"},
+		{name: "not a number", text: "(a,b)", wantErr: "malformed geometric value"},
+		{name: "lseg with one point", text: "[(0,0)]", wantErr: "malformed geometric value"},
+		{name: "out of range", text: "(0,100)", wantErr: "outside the WGS84"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pgGeometricToWKT(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("WKT = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeographyValue(t *testing.T) {
+	mysqlValue := func(srid uint32, geometry []byte) []byte {
+		return append(binary.LittleEndian.AppendUint32(nil, srid), geometry...)
+	}
+
+	tests := []struct {
+		name    string
+		val     any
+		want    any
+		wantMsg string
+	}{
+		{name: "MySQL value", val: mysqlValue(4326, wkb(binary.LittleEndian, 1, 1.0, 2.0)), want: "POINT(1 2)"},
+		{name: "MySQL value without SRID", val: mysqlValue(0, wkb(binary.BigEndian, 1, 1.0, 2.0)), want: "POINT(1 2)"},
+		{name: "MySQL value of another SRID", val: mysqlValue(3857, wkb(binary.LittleEndian, 1, 1.0, 2.0)), wantMsg: "SRID 3857 is not WGS84 (4326)"},
+		// PostGIS output of 'SRID=4326;POINT(1 2)'::geometry
+		{name: "PostGIS hex EWKB", val: "0101000020E6100000000000000000F03F0000000000000040", want: "POINT(1 2)"},
+		{name: "PostGIS hex EWKB as bytes", val: []byte("0101000020e6100000000000000000f03f0000000000000040"), want: "POINT(1 2)"},
+		// PostGIS output of 'SRID=4326;LINESTRING Z (0 0 5, 1 1 5)'::geometry
+		{
+			name: "PostGIS hex EWKB with Z",
+			val:  "01020000A0E610000002000000000000000000000000000000000000000000000000001440000000000000F03F000000000000F03F0000000000001440",
+			want: "LINESTRING(0 0, 1 1)",
+		},
+		{name: "PostGIS hex EWKB of another SRID", val: "0101000020110F0000000000000000F03F0000000000000040", wantMsg: "SRID 3857 is not WGS84 (4326)"},
+		{name: "big-endian WKB hex", val: "000000000140000000000000004008000000000000", want: "POINT(2 3)"},
+		{name: "malformed hex EWKB", val: "0101000020E6100000", wantMsg: "malformed WKB"},
+		{name: "PostgreSQL point", val: []byte("(1,2)"), want: "POINT(1 2)"},
+		{name: "PostgreSQL lseg", val: "[(0,0),(1,1)]", want: "LINESTRING(0 0, 1 1)"},
+		{name: "WKT passes through", val: " POINT(1 2) ", want: "POINT(1 2)"},
+		{name: "GeoJSON passes through", val: `{"type":"Point","coordinates":[1,2]}`, want: `{"type":"Point","coordinates":[1,2]}`},
+		{name: "unsupported type", val: 42, wantMsg: "unsupported value of type int"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, msg := geographyValue(tt.val)
+			if tt.wantMsg != "" {
+				if !strings.Contains(msg, tt.wantMsg) {
+					t.Fatalf("message = %q, want it to contain %q", msg, tt.wantMsg)
+				}
+				return
+			}
+			if msg != "" {
+				t.Fatalf("unexpected message: %s", msg)
+			}
+			if got != tt.want {
+				t.Errorf("value = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
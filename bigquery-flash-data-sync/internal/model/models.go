@@ -19,7 +19,14 @@
 package model
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/bigquery"
@@ -33,8 +40,9 @@ type Savable interface {
 
 // BQTable represents a BigQuery table with its name and schema.
 type BQTable struct {
-	Name   string
-	Schema bigquery.Schema
+	Name             string
+	Schema           bigquery.Schema
+	TimePartitioning *bigquery.TimePartitioning // Partitioning of the table when it is created (nil = unpartitioned)
 }
 
 // DynamicRow represents a row of data with dynamic columns.
@@ -43,15 +51,68 @@ type DynamicRow struct {
 	Values      []any
 }
 
+// Sync modes control which source rows are read on each run.
+const (
+	SyncModeFull        = "full"        // Read the whole table on every run
+	SyncModeIncremental = "incremental" // Read only rows newer than the stored high-water mark
+	SyncModeCDC         = "cdc"         // Apply the row changes logged by the source since the stored log position
+)
+
+// Write modes control how extracted rows are written to the target BigQuery table.
+const (
+	WriteModeAppend   = "append"   // Append extracted rows to the target table
+	WriteModeTruncate = "truncate" // Atomically replace the target table contents with the extracted rows
+	WriteModeMerge    = "merge"    // Upsert extracted rows into the target table on the primary key
+	WriteModeSCD2     = "scd2"     // Keep every version of a row, closing the current one when it changes
+	WriteModeSnapshot = "snapshot" // Append a full copy of the table on every run, partitioned by snapshot date
+)
+
+// Delete detection modes control how rows deleted in the source are reconciled in the target table.
+const (
+	DeleteDetectionNone   = "none"   // Rows deleted in the source stay in the target table
+	DeleteDetectionDelete = "delete" // Delete target rows whose key no longer exists in the source
+	DeleteDetectionFlag   = "flag"   // Set _is_deleted and _deleted_at on target rows whose key no longer exists in the source
+)
+
+// Change detection modes control which extracted rows are written to the target table.
+const (
+	ChangeDetectionNone = "none" // Write every extracted row
+	ChangeDetectionHash = "hash" // Write only rows whose _row_hash differs from the target row with the same key
+)
+
+// Invalid value policies control what happens to a source value that cannot be represented in the
+// BigQuery type of its column, e.g. a MySQL zero date or a TIME beyond 24 hours.
+const (
+	InvalidValueNull = "null" // Load NULL instead and log a warning; skips the row for REQUIRED columns
+	InvalidValueSkip = "skip" // Skip the row, counted as a row parse failure
+	InvalidValueFail = "fail" // Fail the table sync
+)
+
 // TableConfig holds configuration for a single table to sync.
 type TableConfig struct {
-	Name            string   // Source table name
-	TargetTable     string   // Target BigQuery table name (optional, defaults to source name)
-	PrimaryKey      string   // Primary key column for incremental sync
-	TimestampColumn string   // Column to track changes (e.g., updated_at)
-	Columns         []string // Specific columns to sync (empty means all columns)
-	BatchSize       int      // Number of rows per batch (0 = use default)
-	Enabled         bool     // Whether this table sync is enabled
+	Name             string        // Source table name
+	TargetTable      string        // Target BigQuery table name (optional, defaults to source name)
+	PrimaryKey       string        // Primary key column(s), comma-separated for composite keys
+	TimestampColumn  string        // Column to track changes (e.g., updated_at)
+	Columns          []string      // Specific columns to sync (empty means all columns)
+	Query            string        // Custom SELECT the rows are read from instead of the table (optional)
+	Where            string        // Row filter applied to the table or custom query (optional)
+	BatchSize        int           // Maximum rows per load job (0 = use default)
+	Enabled          bool          // Whether this table sync is enabled
+	SyncMode         string        // full (default), incremental or cdc
+	WatermarkOverlap time.Duration // How far before the stored watermark incremental reads start
+	WriteMode        string        // append, truncate, merge, scd2 or snapshot
+	SchemaPolicy     string        // fail, evolve, recreate or quarantine
+	ParallelChunks   int           // Number of primary key ranges extracted in parallel (1 = no chunking)
+	SplitPoints      []int64       // Explicit primary key range boundaries (overrides MIN/MAX splitting)
+	CDCBatchSize     int           // Changes applied to BigQuery per micro-batch (cdc only)
+	CDCSlot          string        // PostgreSQL logical replication slot (cdc only)
+	CDCPublication   string        // PostgreSQL publication the slot decodes (cdc only)
+	DeleteDetection  string        // none, delete or flag
+	ChangeDetection  string        // none or hash
+
+	OnInvalidValue      string            // null, skip or fail
+	InvalidValueColumns map[string]string // Per-column overrides of OnInvalidValue, keyed by lowercased column name
 }
 
 // DatabaseConfig holds configuration for a single database source.
@@ -65,6 +126,16 @@ type DatabaseConfig struct {
 	ConnectionString string
 	Tables           map[string]*TableConfig
 	Enabled          bool
+
+	MaxConcurrentTables int  // Tables of this database synced at once (0 = no limit)
+	ConsistentSnapshot  bool // Read all tables of this database from one consistent snapshot
+
+	DiscoverTables bool     // Tables are discovered from the source catalog when the pipeline starts ({DB}_TABLES=*)
+	IncludeTables  []string // Patterns a discovered table must match one of (empty = every table)
+	ExcludeTables  []string // Patterns of discovered tables to skip
+
+	// DiscoveredTableConfig builds the configuration of a discovered table from the defaults and its per-table settings.
+	DiscoveredTableConfig func(tableName string) (*TableConfig, error)
 }
 
 // Config holds all application configuration.
@@ -76,7 +147,8 @@ type Config struct {
 
 	SyncTimeout      time.Duration
 	DateFormat       string
-	DefaultBatchSize int
+	DefaultBatchSize int   // Maximum rows per load job (0 = no limit)
+	LoadJobMaxBytes  int64 // Maximum NDJSON bytes streamed into one load job (0 = no limit)
 
 	MaxOpenConns    int
 	MaxIdleConns    int
@@ -86,34 +158,62 @@ type Config struct {
 	CreateTables        bool
 	TruncateOnSync      bool
 	MaxRowParseFailures int
+	MaxConcurrentTables int // Tables synced at once across all databases (0 = no limit)
+
+	RetryMaxAttempts    int           // Attempts per operation on transient failures (1 = no retries)
+	RetryInitialBackoff time.Duration // Backoff before the first retry, doubled on every further retry
+	RetryMaxBackoff     time.Duration // Upper bound of the backoff between retries
+
+	StateTable      string // BigQuery table (in BigQueryDatasetID) holding per-table sync state
+	CheckpointTable string // BigQuery table (in BigQueryDatasetID) holding progress of unfinished table syncs
+	Checkpoints     bool   // Save checkpoints so that an interrupted table sync can be resumed
+	Resume          bool   // Continue interrupted table syncs from their checkpoints (--resume)
 }
 
 // Job represents a sync job for a specific table.
 type Job struct {
-	Name             string
-	DatabaseName     string
-	DatabaseType     string
-	ConnectionString string
-	SourceTable      string
-	TargetTable      string
-	Query            string
-	Columns          []string
-	PrimaryKey       string
-	TimestampColumn  string
-	BatchSize        int
-	ParseFunc        func(*sql.Rows, *zap.Logger) (Savable, error)
+	Name              string
+	DatabaseName      string
+	DatabaseType      string
+	ConnectionString  string
+	SourceTable       string
+	TargetTable       string
+	LoadTable         string // Table the load jobs write to: the target itself or a staging table
+	RunID             string
+	Query             string
+	QueryArgs         []any
+	Columns           []string
+	PrimaryKey        string
+	TimestampColumn   string
+	BatchSize         int    // Maximum rows per load job (0 = no limit)
+	MaxLoadBytes      int64  // Maximum bytes per load job (0 = no limit)
+	TruncateFirstLoad bool   // First load job replaces the contents of LoadTable
+	LoadJobIDPrefix   string // Prefix of deterministic load job IDs (empty = random IDs)
+	CommittedLoadJobs int    // Load jobs already committed by an interrupted run being resumed
+	CommittedRows     int64  // Rows already committed by an interrupted run being resumed
+	ParseFunc         func(*sql.Rows, *zap.Logger) (Savable, error)
+
+	// Checkpoint is called with the last row of every committed load job (nil = no checkpoints).
+	Checkpoint func(loadJobs int, rows int64, lastRow map[string]any) error
+
+	// SegmentSealed is called with the sequence number, job ID, row count and last row of every
+	// load job once all its rows are streamed, before the job completes. The job ID is empty when
+	// BigQuery generates it.
+	SegmentSealed func(seq int, jobID string, rows int64, lastRow map[string]any)
 }
 
 // SyncResult holds the result of a sync operation.
 type SyncResult struct {
-	DatabaseName string
-	TableName    string
-	TargetTable  string
-	RowsSynced   int64
-	Duration     time.Duration
-	Error        error
-	StartedAt    time.Time
-	CompletedAt  time.Time
+	DatabaseName    string
+	TableName       string
+	TargetTable     string
+	RowsSynced      int64
+	QuarantineTable string // Versioned table loaded instead of TargetTable after an incompatible schema change
+	Retries         int64  // Operations retried after transient failures
+	Duration        time.Duration
+	Error           error
+	StartedAt       time.Time
+	CompletedAt     time.Time
 }
 
 // SyncSummary holds the overall sync summary.
@@ -136,6 +236,7 @@ type DataSource struct {
 }
 
 // SchemasMatch validates equality of two BigQuery schemas by comparing field names, types, and required attributes.
+// Type parameters of s1 only have to hold the values of s2 (see ParametersFit).
 // Records debug and warning logs for field count differences, type mismatches, and required attribute changes.
 // Returns true only if no missing or mismatched fields are detected.
 func SchemasMatch(s1, s2 bigquery.Schema, logger *zap.Logger) bool {
@@ -165,6 +266,8 @@ func SchemasMatch(s1, s2 bigquery.Schema, logger *zap.Logger) bool {
 			mismatches = append(mismatches, "type:"+field.Name)
 		} else if s1Field.Required != field.Required {
 			mismatches = append(mismatches, "required:"+field.Name)
+		} else if !ParametersFit(s1Field, field) {
+			mismatches = append(mismatches, "parameters:"+field.Name)
 		}
 	}
 
@@ -196,6 +299,32 @@ func (r *DynamicRow) ToSaveable() map[string]any {
 	return result
 }
 
+// Hash returns a stable hex-encoded SHA-256 hash of the row's column names and converted values.
+// Columns are hashed in name order, so the hash does not depend on the order the source returns them in.
+func (r *DynamicRow) Hash() string {
+	order := make([]int, min(len(r.ColumnNames), len(r.Values)))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return r.ColumnNames[order[a]] < r.ColumnNames[order[b]]
+	})
+
+	h := sha256.New()
+	for _, i := range order {
+		// Converted values are plain JSON types, so their encoding is canonical.
+		value, err := json.Marshal(r.Values[i])
+		if err != nil {
+			value = []byte(err.Error())
+		}
+		h.Write([]byte(r.ColumnNames[i]))
+		h.Write([]byte{0})
+		h.Write(value)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 // GetEnabledDatabases returns a slice of enabled database configurations.
 func (c *Config) GetEnabledDatabases() []*DatabaseConfig {
 	var enabled []*DatabaseConfig
@@ -218,6 +347,36 @@ func (db *DatabaseConfig) GetEnabledTables() []*TableConfig {
 	return enabled
 }
 
+// IncludesTable reports whether a discovered table matches one of the include patterns (if any)
+// and none of the exclude patterns.
+func (db *DatabaseConfig) IncludesTable(name string) bool {
+	matchesAny := func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, err := MatchTablePattern(pattern, name); err == nil && ok {
+				return true
+			}
+		}
+		return false
+	}
+	if len(db.IncludeTables) > 0 && !matchesAny(db.IncludeTables) {
+		return false
+	}
+	return !matchesAny(db.ExcludeTables)
+}
+
+// MatchTablePattern reports whether a table name matches a discovery pattern: a regular expression
+// when enclosed in slashes (e.g. /^audit_/), otherwise a glob matching the whole name (e.g. audit_*).
+func MatchTablePattern(pattern, name string) (bool, error) {
+	if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+		if err != nil {
+			return false, err
+		}
+		return re.MatchString(name), nil
+	}
+	return path.Match(pattern, name)
+}
+
 // GetTargetTableName returns the target BigQuery table name.
 // If TargetTable is not set, returns the source table name.
 func (t *TableConfig) GetTargetTableName() string {
@@ -227,7 +386,61 @@ func (t *TableConfig) GetTargetTableName() string {
 	return t.Name
 }
 
-// GetBatchSize returns the batch size to use for this table.
+// IsIncremental reports whether the table is synced incrementally using a timestamp watermark.
+func (t *TableConfig) IsIncremental() bool {
+	return t.SyncMode == SyncModeIncremental
+}
+
+// IsCDC reports whether the table is synced from the source's change log.
+func (t *TableConfig) IsCDC() bool {
+	return t.SyncMode == SyncModeCDC
+}
+
+// GetPrimaryKeyColumns returns the primary key column names.
+// Composite keys are configured as a comma-separated list.
+func (t *TableConfig) GetPrimaryKeyColumns() []string {
+	var keys []string
+	for _, key := range strings.Split(t.PrimaryKey, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// HashesRows reports whether a _row_hash is computed for every extracted row.
+func (t *TableConfig) HashesRows() bool {
+	return t.ChangeDetection == ChangeDetectionHash || t.KeepsHistory()
+}
+
+// KeepsHistory reports whether the target table keeps SCD Type 2 history of the source rows.
+func (t *TableConfig) KeepsHistory() bool {
+	return t.WriteMode == WriteModeSCD2
+}
+
+// TakesSnapshots reports whether every run appends a full copy of the table to the target table.
+func (t *TableConfig) TakesSnapshots() bool {
+	return t.WriteMode == WriteModeSnapshot
+}
+
+// IsChunked reports whether the table is extracted as parallel primary key ranges.
+func (t *TableConfig) IsChunked() bool {
+	return t.ParallelChunks > 1 || len(t.SplitPoints) > 0
+}
+
+// InvalidValuePolicy returns the invalid value policy of a column: its override if it has one,
+// otherwise the table's policy. Column names match case-insensitively, as in BigQuery.
+func (t *TableConfig) InvalidValuePolicy(column string) string {
+	if policy, ok := t.InvalidValueColumns[strings.ToLower(column)]; ok {
+		return policy
+	}
+	if t.OnInvalidValue == "" {
+		return InvalidValueNull
+	}
+	return t.OnInvalidValue
+}
+
+// GetBatchSize returns the maximum number of rows per load job to use for this table.
 // Returns the table-specific batch size if set, otherwise returns the provided default.
 func (t *TableConfig) GetBatchSize(defaultSize int) int {
 	if t.BatchSize > 0 {
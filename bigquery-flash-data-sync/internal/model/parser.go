@@ -12,8 +12,8 @@ import (
 )
 
 // ParseDynamicRow scans a sql.Rows result into a DynamicRow structure.
-// It handles various SQL types and converts them appropriately for BigQuery.
-func ParseDynamicRow(rows *sql.Rows, logger *zap.Logger, dateFormat string) (*DynamicRow, error) {
+// Each value is converted for the BigQuery type of its column by the converter.
+func ParseDynamicRow(rows *sql.Rows, converter *RowConverter) (*DynamicRow, error) {
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
@@ -30,7 +30,11 @@ func ParseDynamicRow(rows *sql.Rows, logger *zap.Logger, dateFormat string) (*Dy
 	}
 
 	for i, val := range values {
-		values[i] = convertValue(val, dateFormat, logger)
+		converted, err := converter.Convert(columns[i], val)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = converted
 	}
 
 	return &DynamicRow{
@@ -46,9 +50,10 @@ func sanitizeInvalidUTF8(logger *zap.Logger, s string, originalLen int) string {
 	return strings.ToValidUTF8(s, "")
 }
 
-// convertValue converts SQL values to appropriate Go types for BigQuery.
+// ConvertValue converts SQL values to appropriate Go types for BigQuery, guessing from the Go type
+// of the value. It is used for columns whose BigQuery type is unknown (see RowConverter).
 // It sanitizes invalid UTF-8 sequences and handles Unsigned Integer overflow.
-func convertValue(val any, dateFormat string, logger *zap.Logger) any {
+func ConvertValue(val any, dateFormat string, logger *zap.Logger) any {
 	if val == nil {
 		return nil
 	}
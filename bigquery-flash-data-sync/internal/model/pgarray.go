@@ -0,0 +1,115 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parsePostgresArray parses the text form of a one-dimensional PostgreSQL array, e.g.
+// {1,NULL,"a \"quoted\" string"}, into its elements. An unquoted NULL element is returned as nil;
+// a quoted "NULL" is the string NULL. A leading dimension decoration such as [0:2]= is skipped.
+func parsePostgresArray(text string) ([]any, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "[") {
+		// Arrays whose lower bound is not 1 are prefixed with their dimensions.
+		eq := strings.IndexByte(text, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("malformed array literal %q", text)
+		}
+		text = strings.TrimSpace(text[eq+1:])
+	}
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return nil, fmt.Errorf("malformed array literal %q", text)
+	}
+
+	body := text[1 : len(text)-1]
+	elements := []any{}
+	if strings.TrimSpace(body) == "" {
+		return elements, nil
+	}
+
+	for i := 0; ; {
+		for i < len(body) && isArraySpace(body[i]) {
+			i++
+		}
+
+		var b strings.Builder
+		quoted := false
+		switch {
+		case i < len(body) && body[i] == '{':
+			return nil, fmt.Errorf("multi-dimensional arrays are not supported")
+		case i < len(body) && body[i] == '"':
+			quoted = true
+			i++
+			for {
+				if i >= len(body) {
+					return nil, fmt.Errorf("unterminated quoted element in array literal %q", text)
+				}
+				c := body[i]
+				if c == '"' {
+					i++
+					break
+				}
+				if c == '\\' && i+1 < len(body) {
+					i++
+				}
+				b.WriteByte(body[i])
+				i++
+			}
+			for i < len(body) && isArraySpace(body[i]) {
+				i++
+			}
+		default:
+			for i < len(body) && body[i] != ',' {
+				c := body[i]
+				if c == '"' || c == '{' || c == '}' {
+					return nil, fmt.Errorf("malformed array literal %q", text)
+				}
+				if c == '\\' && i+1 < len(body) {
+					i++
+				}
+				b.WriteByte(body[i])
+				i++
+			}
+		}
+
+		element := b.String()
+		if !quoted {
+			element = strings.TrimRight(element, " \t\n\r\v\f")
+		}
+		if !quoted && strings.EqualFold(element, "NULL") {
+			elements = append(elements, nil)
+		} else {
+			elements = append(elements, element)
+		}
+
+		if i >= len(body) {
+			return elements, nil
+		}
+		if body[i] != ',' {
+			return nil, fmt.Errorf("malformed array literal %q", text)
+		}
+		i++
+	}
+}
+
+// isArraySpace reports whether c is whitespace PostgreSQL ignores around array elements.
+func isArraySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePostgresArray(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []any
+		wantErr string
+	}{
+		{name: "empty", text: "{}", want: []any{}},
+		{name: "integers", text: "{1,2,3}", want: []any{"1", "2", "3"}},
+		{name: "NULL elements", text: `{NULL,null,"NULL"}`, want: []any{nil, nil, "NULL"}},
+		{name: "quoted elements", text: `{"a \"quoted\" string","b,c","{x}"}`, want: []any{`a "quoted" string`, "b,c", "{x}"}},
+		{name: "quoted empty string", text: `{"",a}`, want: []any{"", "a"}},
+		{name: "quoted whitespace is kept", text: `{" a ",b}`, want: []any{" a ", "b"}},
+		{name: "whitespace around elements", text: " { a , b\t} ", want: []any{"a", "b"}},
+		{name: "escaped comma", text: `{a\,b,c}`, want: []any{"a,b", "c"}},
+		{name: "lower bound decoration", text: "[0:1]={x,y}", want: []any{"x", "y"}},
+		{name: "multi-dimensional", text: "{{1,2},{3,4}}", wantErr: "multi-dimensional arrays are not supported"},
+		{name: "unterminated quote", text: `{"a}`, wantErr: "unterminated quoted element"},
+		{name: "no braces", text: "1,2", wantErr: "malformed array literal"},
+		{name: "quote inside unquoted element", text: `{a"b}`, wantErr: "malformed array literal"},
+		{name: "text after quoted element", text: `{"a" b}`, wantErr: "malformed array literal"},
+		{name: "decoration without =", text: "[0:1]{x}", wantErr: "malformed array literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePostgresArray(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("elements = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parsePostgresRecord parses the text form of a PostgreSQL composite value, e.g.
+// (1,"a, b",,"say ""hi"""), into its attributes in declaration order. An empty unquoted attribute
+// is returned as nil (NULL); a quoted "" is the empty string. Unlike array elements, attributes
+// keep surrounding whitespace.
+func parsePostgresRecord(text string) ([]any, error) {
+	text = strings.TrimSpace(text)
+	if len(text) < 2 || text[0] != '(' || text[len(text)-1] != ')' {
+		return nil, fmt.Errorf("malformed record literal %q", text)
+	}
+
+	body := text[1 : len(text)-1]
+	var attributes []any
+	for i := 0; ; {
+		var b strings.Builder
+		empty := true
+		inQuotes := false
+		for ; i < len(body); i++ {
+			c := body[i]
+			if !inQuotes && c == ',' {
+				break
+			}
+			empty = false
+			switch {
+			case c == '\\':
+				if i+1 >= len(body) {
+					return nil, fmt.Errorf("malformed record literal %q", text)
+				}
+				i++
+				b.WriteByte(body[i])
+			case c == '"' && inQuotes && i+1 < len(body) && body[i+1] == '"':
+				i++
+				b.WriteByte('"')
+			case c == '"':
+				inQuotes = !inQuotes
+			default:
+				b.WriteByte(c)
+			}
+		}
+		if inQuotes {
+			return nil, fmt.Errorf("unterminated quoted attribute in record literal %q", text)
+		}
+
+		if empty {
+			attributes = append(attributes, nil)
+		} else {
+			attributes = append(attributes, b.String())
+		}
+		if i >= len(body) {
+			return attributes, nil
+		}
+		i++ // the comma
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePostgresRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		want    []any
+		wantErr string
+	}{
+		{name: "attributes", text: `(1,"a, b",,"say ""hi""")`, want: []any{"1", "a, b", nil, `say "hi"`}},
+		{name: "quoted empty string", text: `("",x)`, want: []any{"", "x"}},
+		{name: "single NULL attribute", text: "()", want: []any{nil}},
+		{name: "trailing NULL attribute", text: "(a,)", want: []any{"a", nil}},
+		{name: "whitespace is kept", text: "( a ,b)", want: []any{" a ", "b"}},
+		{name: "backslash escape", text: `(a\,b,c\\d)`, want: []any{"a,b", `c\d`}},
+		{name: "nested record", text: `("(1,""x y"")",2)`, want: []any{`(1,"x y")`, "2"}},
+		{name: "array attribute", text: `("{1,2}",NULL)`, want: []any{"{1,2}", "NULL"}},
+		{name: "no parentheses", text: "1,2", wantErr: "malformed record literal"},
+		{name: "unterminated quote", text: `("a)`, wantErr: "unterminated quoted attribute"},
+		{name: "trailing backslash", text: `(a\)`, wantErr: "malformed record literal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePostgresRecord(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("attributes = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Schema policies control what happens when the inferred source schema no longer matches
+// the schema of an existing BigQuery table.
+const (
+	SchemaPolicyFail       = "fail"       // Fail the table sync on any schema change
+	SchemaPolicyEvolve     = "evolve"     // Apply compatible changes in place, fail on incompatible ones
+	SchemaPolicyRecreate   = "recreate"   // Apply compatible changes, drop and recreate the table on incompatible ones
+	SchemaPolicyQuarantine = "quarantine" // Apply compatible changes, load into a versioned table on incompatible ones
+)
+
+// FieldChange describes a change of a single field between the existing and the source schema.
+// Name is the dotted path of the field for nested RECORD fields.
+type FieldChange struct {
+	Name       string
+	From       bigquery.FieldType
+	To         bigquery.FieldType
+	FromParams string // Type parameters of the existing field, e.g. (12,2) (empty = none)
+	ToParams   string // Type parameters the field is changed to (empty = none)
+	Reason     string
+}
+
+// SchemaDiff is the structured difference between an existing BigQuery table schema and the
+// schema inferred from the source.
+type SchemaDiff struct {
+	Added        []string      // Fields only present in the source schema
+	Removed      []string      // Fields only present in the existing table
+	Relaxed      []string      // Fields that are REQUIRED in the table but nullable in the source
+	Widened      []FieldChange // Top-level scalar fields whose type can be widened in place to hold the source type
+	Incompatible []FieldChange // Changes that cannot be applied to the existing table
+}
+
+// IsEmpty reports whether the existing table can load source rows without any change.
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Relaxed) == 0 &&
+		len(d.Widened) == 0 && len(d.Incompatible) == 0
+}
+
+// IsCompatible reports whether every change can be applied without recreating the table.
+func (d *SchemaDiff) IsCompatible() bool {
+	return len(d.Incompatible) == 0
+}
+
+// Summary returns a compact, human readable description of the diff for logs and errors.
+func (d *SchemaDiff) Summary() string {
+	var parts []string
+	if len(d.Added) > 0 {
+		parts = append(parts, "added: "+strings.Join(d.Added, ", "))
+	}
+	if len(d.Removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(d.Removed, ", "))
+	}
+	if len(d.Relaxed) > 0 {
+		parts = append(parts, "relaxed: "+strings.Join(d.Relaxed, ", "))
+	}
+	for _, c := range d.Widened {
+		parts = append(parts, fmt.Sprintf("widened: %s %s%s->%s%s", c.Name, c.From, c.FromParams, c.To, c.ToParams))
+	}
+	for _, c := range d.Incompatible {
+		parts = append(parts, fmt.Sprintf("incompatible: %s %s%s->%s%s (%s)", c.Name, c.From, c.FromParams, c.To, c.ToParams, c.Reason))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// widenableTypes lists the in-place type conversions BigQuery supports through
+// ALTER COLUMN SET DATA TYPE, keyed by the existing column type.
+var widenableTypes = map[bigquery.FieldType][]bigquery.FieldType{
+	bigquery.IntegerFieldType:    {bigquery.NumericFieldType, bigquery.BigNumericFieldType, bigquery.FloatFieldType},
+	bigquery.NumericFieldType:    {bigquery.BigNumericFieldType, bigquery.FloatFieldType},
+	bigquery.BigNumericFieldType: {bigquery.FloatFieldType},
+}
+
+// canWiden reports whether a column of type from can be altered in place to type to.
+func canWiden(from, to bigquery.FieldType) bool {
+	for _, t := range widenableTypes[from] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeParameters returns the parameters of a parameterized field type as they appear in DDL,
+// e.g. (12,2) for NUMERIC(12,2) or (255) for STRING(255), or "" for an unparameterized type.
+func TypeParameters(f *bigquery.FieldSchema) string {
+	switch {
+	case f.MaxLength > 0:
+		return fmt.Sprintf("(%d)", f.MaxLength)
+	case f.Precision > 0 && f.Scale > 0:
+		return fmt.Sprintf("(%d,%d)", f.Precision, f.Scale)
+	case f.Precision > 0:
+		return fmt.Sprintf("(%d)", f.Precision)
+	}
+	return ""
+}
+
+// ParametersFit reports whether a field with the type parameters of existing holds every value of
+// a field of the same type with the parameters of desired: an unparameterized field holds any
+// value, STRING(L) values up to L characters, and NUMERIC(P,S) values with up to P-S integer and
+// S fractional digits.
+func ParametersFit(existing, desired *bigquery.FieldSchema) bool {
+	if existing.MaxLength > 0 && (desired.MaxLength == 0 || desired.MaxLength > existing.MaxLength) {
+		return false
+	}
+	if existing.Precision > 0 {
+		if desired.Precision == 0 || desired.Scale > existing.Scale ||
+			desired.Precision-desired.Scale > existing.Precision-existing.Scale {
+			return false
+		}
+	}
+	return true
+}
+
+// widenedParameters returns the narrowest type parameters of the type of existing that hold the
+// values of both existing and desired, in DDL form.
+func widenedParameters(existing, desired *bigquery.FieldSchema) string {
+	widened := bigquery.FieldSchema{}
+	if desired.MaxLength > 0 && existing.MaxLength > 0 {
+		widened.MaxLength = max(existing.MaxLength, desired.MaxLength)
+	}
+	if desired.Precision > 0 && existing.Precision > 0 {
+		widened.Scale = max(existing.Scale, desired.Scale)
+		widened.Precision = max(existing.Precision-existing.Scale, desired.Precision-desired.Scale) + widened.Scale
+	}
+	return TypeParameters(&widened)
+}
+
+// DiffSchemas compares the schema of an existing table with the schema inferred from the source.
+// A source type that fits into a wider existing type (e.g. INTEGER into NUMERIC) is not a change.
+func DiffSchemas(existing, desired bigquery.Schema) *SchemaDiff {
+	diff := &SchemaDiff{}
+	diffFields("", existing, desired, diff)
+	return diff
+}
+
+func diffFields(prefix string, existing, desired bigquery.Schema, diff *SchemaDiff) {
+	existingByName := make(map[string]*bigquery.FieldSchema, len(existing))
+	for _, f := range existing {
+		existingByName[f.Name] = f
+	}
+	desiredByName := make(map[string]bool, len(desired))
+
+	for _, d := range desired {
+		desiredByName[d.Name] = true
+		name := prefix + d.Name
+
+		e, ok := existingByName[d.Name]
+		if !ok {
+			diff.Added = append(diff.Added, name)
+			continue
+		}
+
+		if e.Repeated != d.Repeated {
+			diff.Incompatible = append(diff.Incompatible, FieldChange{Name: name, From: e.Type, To: d.Type, Reason: "repeated mode changed"})
+			continue
+		}
+
+		if e.Required && !d.Required {
+			diff.Relaxed = append(diff.Relaxed, name)
+		}
+
+		// Only top-level scalar columns can change their type with DDL.
+		inPlace := prefix == "" && !d.Repeated
+
+		switch {
+		case e.Type == d.Type && e.Type == bigquery.RecordFieldType:
+			diffFields(name+".", e.Schema, d.Schema, diff)
+		case e.Type == d.Type && !ParametersFit(e, d):
+			change := FieldChange{Name: name, From: e.Type, To: d.Type, FromParams: TypeParameters(e), ToParams: widenedParameters(e, d)}
+			if inPlace {
+				diff.Widened = append(diff.Widened, change)
+			} else {
+				change.Reason = "type parameters changed"
+				diff.Incompatible = append(diff.Incompatible, change)
+			}
+		case e.Type == d.Type:
+		case canWiden(d.Type, e.Type):
+			// The existing column is already wide enough for the source values.
+		case canWiden(e.Type, d.Type) && inPlace:
+			diff.Widened = append(diff.Widened, FieldChange{Name: name, From: e.Type, To: d.Type})
+		default:
+			diff.Incompatible = append(diff.Incompatible, FieldChange{Name: name, From: e.Type, To: d.Type, Reason: "type changed"})
+		}
+	}
+
+	for _, e := range existing {
+		if !desiredByName[e.Name] {
+			diff.Removed = append(diff.Removed, prefix+e.Name)
+		}
+	}
+}
+
+// EvolveSchema returns the schema to apply to an existing table so that it can load rows of the
+// desired schema without losing history: new fields are appended as NULLABLE, REQUIRED fields
+// missing from or nullable in the source are relaxed, and dropped fields are kept as NULLABLE.
+// Existing field types are kept; type widening is applied separately with DDL.
+func EvolveSchema(existing, desired bigquery.Schema) bigquery.Schema {
+	desiredByName := make(map[string]*bigquery.FieldSchema, len(desired))
+	for _, d := range desired {
+		desiredByName[d.Name] = d
+	}
+
+	evolved := make(bigquery.Schema, 0, len(existing)+len(desired))
+	seen := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		seen[e.Name] = true
+		field := *e
+
+		d, ok := desiredByName[e.Name]
+		switch {
+		case !ok:
+			field.Required = false
+		default:
+			field.Required = e.Required && d.Required
+			if d.Description != "" {
+				field.Description = d.Description
+			}
+			if e.Type == bigquery.RecordFieldType && d.Type == bigquery.RecordFieldType {
+				field.Schema = EvolveSchema(e.Schema, d.Schema)
+			}
+		}
+		evolved = append(evolved, &field)
+	}
+
+	for _, d := range desired {
+		if seen[d.Name] {
+			continue
+		}
+		field := *d
+		field.Required = false
+		evolved = append(evolved, &field)
+	}
+
+	return evolved
+}
+
+// DescribeSchema returns the existing schema with the descriptions of the desired schema applied
+// to its top-level fields, and whether any description changed. Fields the desired schema has no
+// description for keep theirs.
+func DescribeSchema(existing, desired bigquery.Schema) (bigquery.Schema, bool) {
+	descriptions := make(map[string]string, len(desired))
+	for _, d := range desired {
+		if d.Description != "" {
+			descriptions[d.Name] = d.Description
+		}
+	}
+
+	described := make(bigquery.Schema, 0, len(existing))
+	changed := false
+	for _, e := range existing {
+		field := *e
+		if description, ok := descriptions[e.Name]; ok && description != e.Description {
+			field.Description = description
+			changed = true
+		}
+		described = append(described, &field)
+	}
+	return described, changed
+}
+
+// SchemaFingerprint returns a short, stable hash of a schema's field names, types and modes.
+func SchemaFingerprint(schema bigquery.Schema) string {
+	h := sha256.New()
+	writeSchemaFingerprint(h, "", schema)
+	return hex.EncodeToString(h.Sum(nil))[:8]
+}
+
+func writeSchemaFingerprint(w io.Writer, prefix string, schema bigquery.Schema) {
+	for _, f := range schema {
+		fmt.Fprintf(w, "%s%s:%s:%t:%t;", prefix, f.Name, f.Type, f.Required, f.Repeated)
+		if f.Type == bigquery.RecordFieldType {
+			writeSchemaFingerprint(w, prefix+f.Name+".", f.Schema)
+		}
+	}
+}
@@ -0,0 +1,580 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	gomysql "github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/go-sql-driver/mysql"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// binlogPosition is the change log position of a MySQL CDC table, stored as JSON in the
+// cdc_position column of the sync state table. When the server has GTIDs enabled, reading
+// resumes from the GTID set, which survives binlog purges and failovers; otherwise from File:Pos.
+type binlogPosition struct {
+	File    string `json:"file"`
+	Pos     uint32 `json:"pos"`
+	GTIDSet string `json:"gtid_set,omitempty"`
+}
+
+// String returns the stored form of the position.
+func (p binlogPosition) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// reached reports whether the position is at or after end.
+func (p binlogPosition) reached(end binlogPosition) bool {
+	if p.File == "" {
+		return false
+	}
+	return gomysql.Position{Name: p.File, Pos: p.Pos}.Compare(gomysql.Position{Name: end.File, Pos: end.Pos}) >= 0
+}
+
+// parseBinlogPosition parses a position stored by binlogPosition.String.
+func parseBinlogPosition(s string) (binlogPosition, error) {
+	var p binlogPosition
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return binlogPosition{}, fmt.Errorf("invalid binlog position %q: %w", s, err)
+	}
+	if p.File == "" && p.GTIDSet == "" {
+		return binlogPosition{}, fmt.Errorf("invalid binlog position %q: neither file nor GTID set recorded", s)
+	}
+	return p, nil
+}
+
+// currentBinlogPosition returns the position of the end of the source's binary log.
+func currentBinlogPosition(ctx context.Context, db *sql.DB) (binlogPosition, error) {
+	// MySQL 8.4 removed SHOW MASTER STATUS in favour of SHOW BINARY LOG STATUS.
+	rows, err := db.QueryContext(ctx, "SHOW BINARY LOG STATUS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW MASTER STATUS")
+		if err != nil {
+			return binlogPosition{}, fmt.Errorf("failed to read binary log status: %w", err)
+		}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return binlogPosition{}, fmt.Errorf("failed to read binary log status: %w", err)
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return binlogPosition{}, fmt.Errorf("failed to read binary log status: %w", err)
+		}
+		return binlogPosition{}, errors.New("binary logging is not enabled on the source server")
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return binlogPosition{}, fmt.Errorf("failed to read binary log status: %w", err)
+	}
+
+	var p binlogPosition
+	for i, column := range columns {
+		switch column {
+		case "File":
+			p.File = values[i].String
+		case "Position":
+			pos, err := strconv.ParseUint(values[i].String, 10, 32)
+			if err != nil {
+				return binlogPosition{}, fmt.Errorf("invalid binary log position %q: %w", values[i].String, err)
+			}
+			p.Pos = uint32(pos)
+		case "Executed_Gtid_Set":
+			p.GTIDSet = strings.ReplaceAll(values[i].String, "\n", "")
+		}
+	}
+	return p, nil
+}
+
+// checkBinlogFormat ensures the server logs full row images. With statement-based logging no row
+// events are written, and with binlog_row_image=MINIMAL or NOBLOB unchanged columns are missing
+// from update images, which would overwrite them with NULL in BigQuery.
+func checkBinlogFormat(ctx context.Context, db *sql.DB) error {
+	var format, rowImage string
+	if err := db.QueryRowContext(ctx, "SELECT @@GLOBAL.binlog_format, @@GLOBAL.binlog_row_image").Scan(&format, &rowImage); err != nil {
+		return fmt.Errorf("failed to read binary log format: %w", err)
+	}
+	if !strings.EqualFold(format, "ROW") || !strings.EqualFold(rowImage, "FULL") {
+		return fmt.Errorf("change data capture requires binlog_format=ROW and binlog_row_image=FULL, the server has binlog_format=%s and binlog_row_image=%s",
+			format, rowImage)
+	}
+	return nil
+}
+
+// binlogColumn describes a column of the source table as it appears in row events.
+type binlogColumn struct {
+	Name     string
+	Unsigned bool
+	Labels   []string // Values of an ENUM or SET column, in declaration order
+	Set      bool     // SET column: row events carry a bitmask of Labels rather than an index
+}
+
+// sourceTableColumns returns the columns of a MySQL table in the order row events list them.
+// Row events only carry column names with binlog_row_metadata=FULL, so they are read from the
+// information schema instead.
+func sourceTableColumns(ctx context.Context, db *sql.DB, schema, table string) ([]binlogColumn, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION",
+		schema, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of '%s.%s': %w", schema, table, err)
+	}
+	defer rows.Close()
+
+	var columns []binlogColumn
+	for rows.Next() {
+		var name, columnType string
+		if err := rows.Scan(&name, &columnType); err != nil {
+			return nil, fmt.Errorf("failed to read columns of '%s.%s': %w", schema, table, err)
+		}
+		lower := strings.ToLower(columnType)
+		column := binlogColumn{
+			Name:     name,
+			Unsigned: strings.Contains(lower, "unsigned"),
+		}
+		if strings.HasPrefix(lower, "enum(") || strings.HasPrefix(lower, "set(") {
+			column.Set = strings.HasPrefix(lower, "set(")
+			column.Labels = mysqlEnumLabels(columnType)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of '%s.%s': %w", schema, table, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s.%s' not found", schema, table)
+	}
+	return columns, nil
+}
+
+// mysqlEnumLabels returns the values declared by an ENUM or SET column type, e.g. enum('a','b').
+// Quotes inside a value are doubled, and backslashes escape the next character.
+func mysqlEnumLabels(columnType string) []string {
+	var labels []string
+	var b strings.Builder
+	inQuotes := false
+	for i := 0; i < len(columnType); i++ {
+		c := columnType[i]
+		switch {
+		case !inQuotes:
+			if c == '\'' {
+				inQuotes = true
+				b.Reset()
+			}
+		case c == '\\' && i+1 < len(columnType):
+			i++
+			b.WriteByte(columnType[i])
+		case c == '\'' && i+1 < len(columnType) && columnType[i+1] == '\'':
+			i++
+			b.WriteByte('\'')
+		case c == '\'':
+			inQuotes = false
+			labels = append(labels, b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return labels
+}
+
+// enumSetValue maps the value row events carry for an ENUM or SET column back to its text, as
+// the snapshot reads it: the 1-based index of an ENUM value (0 is the empty string MySQL stores
+// for invalid values), or the bitmask of the values of a SET, joined by commas.
+func enumSetValue(column binlogColumn, val any) any {
+	n, ok := val.(int64)
+	if !ok {
+		return val
+	}
+	if !column.Set {
+		if n < 1 || n > int64(len(column.Labels)) {
+			return ""
+		}
+		return column.Labels[n-1]
+	}
+	var members []string
+	for i, label := range column.Labels {
+		if n&(1<<i) != 0 {
+			members = append(members, label)
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+// binlogServerID returns the replica server ID the binlog reader of a table registers with.
+// It is derived from the table so that tables read concurrently never share one.
+func binlogServerID(dbName, tableName string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(dbName + "." + tableName))
+	return h.Sum32() | 1<<31
+}
+
+// newBinlogSyncer creates a binlog reader connected with the credentials and TLS settings of
+// the database's connection string.
+func newBinlogSyncer(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig) (*replication.BinlogSyncer, error) {
+	dsn, err := mysql.ParseDSN(dbConfig.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection string: %w", err)
+	}
+	port, err := strconv.ParseUint(dbConfig.Port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", dbConfig.Port, err)
+	}
+
+	return replication.NewBinlogSyncer(replication.BinlogSyncerConfig{
+		ServerID:                binlogServerID(dbConfig.Name, tableConfig.Name),
+		Flavor:                  gomysql.MySQLFlavor,
+		Host:                    dbConfig.Host,
+		Port:                    uint16(port),
+		User:                    dsn.User,
+		Password:                dsn.Passwd,
+		TLSConfig:               dsn.TLS,
+		ParseTime:               true,
+		TimestampStringLocation: time.UTC,
+		Logger:                  slog.New(slog.DiscardHandler),
+	}), nil
+}
+
+// syncBinlogChanges applies the changes logged in the source's binary log for a table since the
+// stored position from, up to the end of the binary log at the time of the call. Changes are
+// applied in micro-batches of tableConfig.CDCBatchSize, and the position is saved after each.
+// Returns the number of change records applied.
+func syncBinlogChanges(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, targetTable, from string, retry *retrier, logger *zap.Logger) (int64, error) {
+	start, err := parseBinlogPosition(from)
+	if err != nil {
+		return 0, err
+	}
+
+	var end binlogPosition
+	err = retry.do(ctx, "read binary log position", func(ctx context.Context) error {
+		if err := checkBinlogFormat(ctx, db); err != nil {
+			return err
+		}
+		end, err = currentBinlogPosition(ctx, db)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if start.reached(end) {
+		logger.Info("No new binary log events since the stored position", zap.String("position", from))
+		return 0, nil
+	}
+
+	sourceSchema, sourceTable, err := sourceSchemaAndTable(dbConfig, tableConfig)
+	if err != nil {
+		return 0, err
+	}
+	var columns []binlogColumn
+	err = retry.do(ctx, "read source columns", func(ctx context.Context) error {
+		columns, err = sourceTableColumns(ctx, db, sourceSchema, sourceTable)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sink, dropStaging, err := newChangeSink(ctx, bqClient, cfg, runID, dbConfig, tableConfig, schema, targetTable, retry, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer dropStaging()
+
+	syncer, err := newBinlogSyncer(dbConfig, tableConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer syncer.Close()
+
+	var streamer *replication.BinlogStreamer
+	var gset *gomysql.MysqlGTIDSet
+	if start.GTIDSet != "" {
+		parsed, err := gomysql.ParseMysqlGTIDSet(start.GTIDSet)
+		if err != nil {
+			return 0, fmt.Errorf("invalid GTID set %q: %w", start.GTIDSet, err)
+		}
+		gset = parsed.(*gomysql.MysqlGTIDSet)
+		streamer, err = syncer.StartSyncGTID(gset.Clone())
+		if err != nil {
+			return 0, fmt.Errorf("failed to start binlog replication from GTID set: %w", err)
+		}
+	} else {
+		streamer, err = syncer.StartSync(gomysql.Position{Name: start.File, Pos: start.Pos})
+		if err != nil {
+			return 0, fmt.Errorf("failed to start binlog replication from %s:%d: %w", start.File, start.Pos, err)
+		}
+	}
+
+	logger.Info("Reading binary log changes",
+		zap.String("from", from),
+		zap.String("to", end.String()))
+
+	fields := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = true
+	}
+
+	r := &binlogReader{
+		schema:  sourceSchema,
+		table:   sourceTable,
+		columns: columns,
+		fields:  fields,
+		pos:     start,
+		end:     end,
+		gset:    gset,
+		sink:    sink,
+		logger:  logger,
+	}
+	r.savePosition = func(ctx context.Context) error {
+		return saveCDCPosition(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name, r.pos.String())
+	}
+
+	if err := r.run(ctx, streamer); err != nil {
+		sink.applier.Abort(err)
+		return sink.applier.Applied(), err
+	}
+	return sink.applier.Applied(), nil
+}
+
+// binlogReader turns the row events of one table into change records. The position saved with a
+// micro-batch is never inside a transaction.
+type binlogReader struct {
+	schema  string
+	table   string
+	columns []binlogColumn
+	fields  map[string]bool // Columns synced to BigQuery
+	pos     binlogPosition
+	end     binlogPosition
+	gset    *gomysql.MysqlGTIDSet // Executed GTID set, nil without GTIDs
+	sink    *changeSink
+	logger  *zap.Logger
+
+	savePosition func(ctx context.Context) error
+
+	inTxn      bool
+	txnGTID    string
+	commitTime time.Time
+}
+
+// run reads events until the end position is reached, then applies the last micro-batch.
+func (r *binlogReader) run(ctx context.Context, streamer *replication.BinlogStreamer) error {
+	for !r.pos.reached(r.end) {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to read binary log: %w", err)
+		}
+		if err := r.handle(ev.Header, ev.Event); err != nil {
+			return err
+		}
+		if !r.inTxn && r.sink.applier.Full() {
+			if err := r.sink.applier.Flush(r.savePosition); err != nil {
+				return err
+			}
+		}
+	}
+	return r.sink.applier.Flush(r.savePosition)
+}
+
+// handle processes one binlog event.
+func (r *binlogReader) handle(header *replication.EventHeader, event replication.Event) error {
+	switch e := event.(type) {
+	case *replication.RotateEvent:
+		r.pos.File = string(e.NextLogName)
+		r.pos.Pos = uint32(e.Position)
+		return nil
+	case *replication.GTIDEvent:
+		next, err := e.GTIDNext()
+		if err != nil {
+			return fmt.Errorf("invalid GTID event: %w", err)
+		}
+		r.beginTxn(next.String(), e.ImmediateCommitTime())
+	case *replication.QueryEvent:
+		if string(e.Query) == "BEGIN" {
+			if !r.inTxn {
+				r.beginTxn("", time.Time{})
+			}
+			return nil
+		}
+		// DDL and other statements commit implicitly.
+		if err := r.commit(header); err != nil {
+			return err
+		}
+	case *replication.XIDEvent:
+		if err := r.commit(header); err != nil {
+			return err
+		}
+	case *replication.TransactionPayloadEvent:
+		// Compressed transaction: its events carry no positions of their own.
+		for _, inner := range e.Events {
+			if err := r.handle(&replication.EventHeader{Timestamp: header.Timestamp, LogPos: header.LogPos}, inner.Event); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *replication.RowsEvent:
+		if e.Table == nil || string(e.Table.Schema) != r.schema || string(e.Table.Table) != r.table {
+			return nil
+		}
+		if err := r.addRows(e); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if !r.inTxn && header.LogPos > 0 {
+		r.pos.Pos = header.LogPos
+	}
+	return nil
+}
+
+// beginTxn starts buffering the changes of a transaction.
+func (r *binlogReader) beginTxn(gtid string, commitTime time.Time) {
+	r.inTxn = true
+	r.txnGTID = gtid
+	r.commitTime = commitTime
+	r.sink.begin()
+}
+
+// commit stages the buffered changes of the committed transaction and moves the position past it.
+func (r *binlogReader) commit(header *replication.EventHeader) error {
+	committedAt := r.commitTime
+	if committedAt.IsZero() {
+		committedAt = time.Unix(int64(header.Timestamp), 0)
+	}
+	if err := r.sink.commit(committedAt); err != nil {
+		return err
+	}
+
+	if r.gset != nil && r.txnGTID != "" {
+		if err := r.gset.Update(r.txnGTID); err != nil {
+			return fmt.Errorf("failed to update GTID set: %w", err)
+		}
+		r.pos.GTIDSet = r.gset.String()
+	}
+	if header.LogPos > 0 {
+		r.pos.Pos = header.LogPos
+	}
+
+	r.inTxn = false
+	r.txnGTID = ""
+	r.commitTime = time.Time{}
+	return nil
+}
+
+// addRows buffers the changes of a rows event.
+func (r *binlogReader) addRows(e *replication.RowsEvent) error {
+	switch e.Type() {
+	case replication.EnumRowsEventTypeInsert:
+		for _, values := range e.Rows {
+			row, err := r.rowFromValues(values, e.Table.ColumnType)
+			if err != nil {
+				return err
+			}
+			r.sink.insert(row)
+		}
+	case replication.EnumRowsEventTypeDelete:
+		for _, values := range e.Rows {
+			row, err := r.rowFromValues(values, e.Table.ColumnType)
+			if err != nil {
+				return err
+			}
+			r.sink.delete(row)
+		}
+	case replication.EnumRowsEventTypeUpdate:
+		// Update events list the before and after image of every row.
+		for i := 0; i+1 < len(e.Rows); i += 2 {
+			before, err := r.rowFromValues(e.Rows[i], e.Table.ColumnType)
+			if err != nil {
+				return err
+			}
+			after, err := r.rowFromValues(e.Rows[i+1], e.Table.ColumnType)
+			if err != nil {
+				return err
+			}
+			r.sink.update(before, after)
+		}
+	}
+	return nil
+}
+
+// rowFromValues converts the column values of a row image into a row of the synced columns.
+// columnTypes are the binlog column types of the table map of the row event.
+func (r *binlogReader) rowFromValues(values []any, columnTypes []byte) (map[string]any, error) {
+	if len(values) != len(r.columns) {
+		return nil, fmt.Errorf("row event of '%s.%s' has %d columns, expected %d (was the table altered during the sync?)",
+			r.schema, r.table, len(values), len(r.columns))
+	}
+	row := make(map[string]any, len(r.fields))
+	for i, column := range r.columns {
+		if !r.fields[column.Name] {
+			continue
+		}
+		val := values[i]
+		if column.Unsigned {
+			val = unsignedBinlogValue(val, i < len(columnTypes) && columnTypes[i] == gomysql.MYSQL_TYPE_INT24)
+		}
+		if column.Labels != nil {
+			val = enumSetValue(column, val)
+		}
+		converted, err := r.sink.convert(column.Name, val)
+		if err != nil {
+			return nil, err
+		}
+		row[column.Name] = converted
+	}
+	return row, nil
+}
+
+// unsignedBinlogValue reinterprets an integer decoded from a row event as unsigned; row events
+// do not record signedness, so unsigned values above the signed maximum decode as negative.
+// MEDIUMINT values (mediumInt) are decoded into a sign-extended int32.
+func unsignedBinlogValue(val any, mediumInt bool) any {
+	switch v := val.(type) {
+	case int8:
+		return uint8(v)
+	case int16:
+		return uint16(v)
+	case int32:
+		if mediumInt {
+			return uint32(v) & 0xFFFFFF
+		}
+		return uint32(v)
+	case int64:
+		return uint64(v)
+	}
+	return val
+}
@@ -24,6 +24,7 @@ import (
 	"database/sql"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
@@ -54,7 +55,8 @@ func validateBigQueryIdentifier(identifier string, identifierType string) error
 }
 
 // SchemaInferrer defines the function signature for database-specific schema inference.
-type SchemaInferrer func(*sql.DB, string, string, *zap.Logger) (bigquery.Schema, error)
+// The trailing arguments are bound to the placeholders of the query.
+type SchemaInferrer func(*sql.DB, string, string, *zap.Logger, ...any) (bigquery.Schema, error)
 
 // schemaInferrers is a registry mapping database types to their inference functions.
 // This allows for easy extension without modifying the main InferSchemaFromDatabase function.
@@ -66,7 +68,8 @@ var schemaInferrers = map[string]SchemaInferrer{
 
 // InferSchemaFromDatabase infers a BigQuery schema from a SQL database query.
 // It uses a map-based strategy to select the correct inference logic based on dbType.
-func InferSchemaFromDatabase(db *sql.DB, dbType string, dbName string, query string, logger *zap.Logger) (bigquery.Schema, error) {
+// args are bound to the placeholders of the query, e.g. the named parameters of a custom QUERY.
+func InferSchemaFromDatabase(db *sql.DB, dbType string, dbName string, query string, logger *zap.Logger, args ...any) (bigquery.Schema, error) {
 	logger.Debug("Inferring schema from database",
 		zap.String("db_type", dbType),
 		zap.String("database", dbName),
@@ -82,7 +85,7 @@ func InferSchemaFromDatabase(db *sql.DB, dbType string, dbName string, query str
 		inferrer = schemaInferrers["mysql"]
 	}
 
-	return inferrer(db, dbName, query, logger)
+	return inferrer(db, dbName, query, logger, args...)
 }
 
 // mysqlTypeToBigQueryType maps common MySQL database types to BigQuery types.
@@ -103,12 +106,22 @@ func mysqlTypeToBigQueryType(mysqlType string, logger *zap.Logger) bigquery.Fiel
 		return bigquery.TimeFieldType
 	case "DATETIME", "TIMESTAMP":
 		return bigquery.TimestampFieldType
-	case "BOOLEAN", "BOOL", "BIT":
+	case "BOOLEAN", "BOOL":
+		return bigquery.BooleanFieldType
+	case "BIT":
+		// BIT(1) is a flag; wider bit fields are loaded as their integer value. A BIT of unknown
+		// width, e.g. a query column, stays BOOLEAN so that existing flag columns keep their type.
+		if mysqlBitWidth(mysqlType) > 1 {
+			return bigquery.IntegerFieldType
+		}
 		return bigquery.BooleanFieldType
 	case "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB", "BINARY", "VARBINARY":
 		return bigquery.BytesFieldType
 	case "JSON":
 		return bigquery.JSONFieldType
+	case "GEOMETRY", "POINT", "LINESTRING", "POLYGON", "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON",
+		"GEOMETRYCOLLECTION", "GEOMCOLLECTION":
+		return bigquery.GeographyFieldType
 	default:
 		logger.Warn("Unknown MySQL type, defaulting to STRING",
 			zap.String("mysql_type", mysqlType),
@@ -117,13 +130,46 @@ func mysqlTypeToBigQueryType(mysqlType string, logger *zap.Logger) bigquery.Fiel
 	}
 }
 
-// postgresTypeToBigQueryType maps common PostgreSQL database types to BigQuery types.
+// mysqlBitWidth returns the width n of a BIT(n) type, or 1 when the type does not declare one,
+// e.g. the column types of a query.
+func mysqlBitWidth(mysqlType string) int {
+	_, width, ok := strings.Cut(mysqlType, "(")
+	if !ok {
+		return 1
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(width, ")")))
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+// postgresArrayElementType returns the element type of a PostgreSQL array type, named as the
+// catalog (integer[]) or the driver (_INT4) names it, and whether pgType is an array type.
+func postgresArrayElementType(pgType string) (string, bool) {
+	if element, ok := strings.CutSuffix(pgType, "[]"); ok {
+		return element, true
+	}
+	if element, ok := strings.CutPrefix(pgType, "_"); ok {
+		return element, true
+	}
+	return pgType, false
+}
+
+// isPostgresArrayType reports whether pgType is a PostgreSQL array type.
+func isPostgresArrayType(pgType string) bool {
+	_, ok := postgresArrayElementType(pgType)
+	return ok
+}
+
+// postgresTypeToBigQueryType maps common PostgreSQL database types to BigQuery types. Array types
+// map to the type of their elements; their fields are REPEATED.
 func postgresTypeToBigQueryType(pgType string, logger *zap.Logger) bigquery.FieldType {
+	pgType, _ = postgresArrayElementType(pgType)
 	t := strings.ToUpper(strings.Split(pgType, "(")[0])
-	t = strings.TrimSuffix(t, "[]")
 
 	switch t {
-	case "VARCHAR", "CHAR", "CHARACTER", "CHARACTER VARYING", "TEXT", "NAME", "UUID", "CITEXT":
+	case "VARCHAR", "CHAR", "BPCHAR", "CHARACTER", "CHARACTER VARYING", "TEXT", "NAME", "UUID", "CITEXT":
 		return bigquery.StringFieldType
 	case "INT", "INT2", "INT4", "INT8", "INTEGER", "SMALLINT", "BIGINT", "SERIAL", "BIGSERIAL", "SMALLSERIAL":
 		return bigquery.IntegerFieldType
@@ -145,9 +191,19 @@ func postgresTypeToBigQueryType(pgType string, logger *zap.Logger) bigquery.Fiel
 		return bigquery.JSONFieldType
 	case "INET", "CIDR", "MACADDR", "MACADDR8":
 		return bigquery.StringFieldType
+	case "BIT", "VARBIT", "BIT VARYING":
+		// Bit strings are loaded as their text, e.g. 0101.
+		return bigquery.StringFieldType
 	case "INTERVAL":
 		return bigquery.StringFieldType
-	case "POINT", "LINE", "LSEG", "BOX", "PATH", "POLYGON", "CIRCLE":
+	case "GEOMETRY", "GEOGRAPHY":
+		// PostGIS types; the SRID of geometry values must be 4326.
+		return bigquery.GeographyFieldType
+	case "POINT", "LSEG", "BOX", "PATH", "POLYGON":
+		// Built-in geometric types have no SRID; their coordinates are read as longitude/latitude.
+		return bigquery.GeographyFieldType
+	case "LINE", "CIRCLE":
+		// Infinite lines and circles have no GEOGRAPHY equivalent.
 		return bigquery.StringFieldType
 	default:
 		logger.Warn("Unknown PostgreSQL type, defaulting to STRING",
@@ -158,8 +214,9 @@ func postgresTypeToBigQueryType(pgType string, logger *zap.Logger) bigquery.Fiel
 }
 
 // inferSchema is shared logic for schema inference across DBs (reduces duplication).
-func inferSchema(db *sql.DB, dbName string, query string, logger *zap.Logger, typeMapper func(string, *zap.Logger) bigquery.FieldType) (bigquery.Schema, error) {
-	rows, err := db.Query(query)
+// isArray reports which database types are arrays, whose fields are REPEATED (nil = no arrays).
+func inferSchema(db *sql.DB, dbName string, query string, args []any, logger *zap.Logger, typeMapper func(string, *zap.Logger) bigquery.FieldType, isArray func(string) bool) (bigquery.Schema, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("schema inference query failed: %w", err)
 	}
@@ -189,6 +246,11 @@ func inferSchema(db *sql.DB, dbName string, query string, logger *zap.Logger, ty
 			Type:     bqType,
 			Required: ok && !nullable,
 		}
+		if isArray != nil && isArray(col.DatabaseTypeName()) {
+			// REPEATED fields cannot be REQUIRED.
+			field.Repeated = true
+			field.Required = false
+		}
 
 		schema = append(schema, field)
 
@@ -206,12 +268,12 @@ func inferSchema(db *sql.DB, dbName string, query string, logger *zap.Logger, ty
 
 // InferSchemaFromMySQL connects to the source DB, runs a LIMIT 1 query,
 // and builds a BigQuery Schema based on the returned column types.
-func InferSchemaFromMySQL(db *sql.DB, dbName string, query string, logger *zap.Logger) (bigquery.Schema, error) {
+func InferSchemaFromMySQL(db *sql.DB, dbName string, query string, logger *zap.Logger, args ...any) (bigquery.Schema, error) {
 	logger.Debug("Inferring schema from MySQL database",
 		zap.String("database", dbName),
 		zap.String("query", query))
 
-	schema, err := inferSchema(db, dbName, query, logger, mysqlTypeToBigQueryType)
+	schema, err := inferSchema(db, dbName, query, args, logger, mysqlTypeToBigQueryType, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -225,15 +287,30 @@ func InferSchemaFromMySQL(db *sql.DB, dbName string, query string, logger *zap.L
 
 // InferSchemaFromPostgres connects to the source PostgreSQL DB, runs a LIMIT 1 query,
 // and builds a BigQuery Schema based on the returned column types.
-func InferSchemaFromPostgres(db *sql.DB, dbName string, query string, logger *zap.Logger) (bigquery.Schema, error) {
+func InferSchemaFromPostgres(db *sql.DB, dbName string, query string, logger *zap.Logger, args ...any) (bigquery.Schema, error) {
 	logger.Debug("Inferring schema from PostgreSQL database",
 		zap.String("database", dbName),
 		zap.String("query", query))
 
-	schema, err := inferSchema(db, dbName, query, logger, postgresTypeToBigQueryType)
+	// The driver only names built-in types. Columns of other types (enums, composite types, extension
+	// types such as PostGIS geometry) are resolved through pg_type by their OID.
+	unresolved := false
+	typeMapper := func(pgType string, logger *zap.Logger) bigquery.FieldType {
+		if pgType == "" {
+			unresolved = true
+			return bigquery.StringFieldType
+		}
+		return postgresTypeToBigQueryType(pgType, logger)
+	}
+	schema, err := inferSchema(db, dbName, query, args, logger, typeMapper, isPostgresArrayType)
 	if err != nil {
 		return nil, err
 	}
+	if unresolved {
+		if err := resolvePostgresQueryTypes(db, query, args, schema, logger); err != nil {
+			return nil, err
+		}
+	}
 
 	logger.Info("PostgreSQL schema inference complete",
 		zap.String("database", dbName),
@@ -242,11 +319,14 @@ func InferSchemaFromPostgres(db *sql.DB, dbName string, query string, logger *za
 	return schema, nil
 }
 
-// createOrUpdateTable ensures that a target table in BigQuery exists and that its schema matches the provided schema.
-// If the table does not exist, it is created. If the schema differs, the table schema is updated.
-func createOrUpdateTable(ctx context.Context, client *bigquery.Client, datasetID string, table model.BQTable, logger *zap.Logger) error {
+// createOrUpdateTable ensures that a target table in BigQuery exists and can load rows of the provided schema.
+// If the table does not exist, it is created. If the schema differs, the structured schema diff between the
+// existing table and the provided schema is resolved according to the schema policy. It returns the name of
+// the table rows must be loaded into, which only differs from table.Name when an incompatible change was
+// quarantined into a versioned table.
+func createOrUpdateTable(ctx context.Context, client *bigquery.Client, datasetID string, table model.BQTable, policy string, logger *zap.Logger) (string, error) {
 	if err := validateBigQueryIdentifier(table.Name, "Target table name"); err != nil {
-		return err
+		return "", err
 	}
 
 	logger.Info("Checking BigQuery table",
@@ -257,90 +337,238 @@ func createOrUpdateTable(ctx context.Context, client *bigquery.Client, datasetID
 	metadata, err := tableRef.Metadata(ctx)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "Not found") || strings.Contains(err.Error(), "notFound") {
-			logger.Info("Table not found, creating new table",
-				zap.String("dataset", datasetID),
-				zap.String("table", table.Name),
-				zap.Int("schema_fields", len(table.Schema)))
-
-			err = tableRef.Create(ctx, &bigquery.TableMetadata{
-				Name:   table.Name,
-				Schema: table.Schema,
-			})
-			if err != nil {
-				return fmt.Errorf("failed to create table '%s': %w", table.Name, err)
-			}
-
-			logger.Info("Table created successfully",
-				zap.String("dataset", datasetID),
-				zap.String("table", table.Name))
-			return nil
+		if isNotFoundError(err) {
+			return table.Name, createTable(ctx, tableRef, datasetID, table, logger)
 		}
-		return fmt.Errorf("failed to get table metadata for '%s': %w", table.Name, err)
+		return "", fmt.Errorf("failed to get table metadata for '%s': %w", table.Name, err)
 	}
 
-	// Table exists, check if schema matches
-	if !model.SchemasMatch(metadata.Schema, table.Schema, logger) {
-		logger.Warn("Schema mismatch detected, attempting update",
+	if table.TimePartitioning != nil && metadata.TimePartitioning == nil {
+		// Partitioning cannot be added to an existing table; rows still load, only unpartitioned.
+		logger.Warn("Existing table is not partitioned, recreate it to partition it",
 			zap.String("dataset", datasetID),
 			zap.String("table", table.Name),
-			zap.Int("existing_fields", len(metadata.Schema)),
-			zap.Int("new_fields", len(table.Schema)))
-
-		update := bigquery.TableMetadataToUpdate{Schema: table.Schema}
-		_, updateErr := tableRef.Update(ctx, update, metadata.ETag)
-
-		if updateErr != nil {
-			// Check for critical schema errors that require table recreation
-			isCriticalError := (strings.Contains(updateErr.Error(), "changed type") ||
-				strings.Contains(updateErr.Error(), "is missing") ||
-				strings.Contains(updateErr.Error(), "Precondition")) &&
-				(strings.Contains(updateErr.Error(), "invalid") ||
-					strings.Contains(updateErr.Error(), "cannot"))
-
-			if isCriticalError {
-				logger.Error("Critical schema error detected, recreating table",
-					zap.String("dataset", datasetID),
-					zap.String("table", table.Name),
-					zap.Error(updateErr))
-
-				logger.Warn("Recreating table will delete all existing data",
-					zap.String("dataset", datasetID),
-					zap.String("table", table.Name))
-
-				// Delete existing table
-				if delErr := tableRef.Delete(ctx); delErr != nil {
-					return fmt.Errorf("failed to delete table '%s' with bad schema: %w", table.Name, delErr)
-				}
-				logger.Info("Table deleted",
-					zap.String("dataset", datasetID),
-					zap.String("table", table.Name))
-
-				if createErr := tableRef.Create(ctx, &bigquery.TableMetadata{
-					Name:   table.Name,
-					Schema: table.Schema,
-				}); createErr != nil {
-					return fmt.Errorf("failed to recreate table '%s' with correct schema: %w", table.Name, createErr)
-				}
-
-				logger.Info("Table successfully recreated with corrected schema",
-					zap.String("dataset", datasetID),
-					zap.String("table", table.Name))
-				return nil
-			}
-
-			return fmt.Errorf("failed to update table schema for '%s': %w", table.Name, updateErr)
-		}
+			zap.String("partition_field", table.TimePartitioning.Field))
+	}
 
-		logger.Info("Table schema updated successfully",
+	// Table exists, check if schema matches
+	if model.SchemasMatch(metadata.Schema, table.Schema, logger) {
+		logger.Debug("Table schema is up to date",
 			zap.String("dataset", datasetID),
 			zap.String("table", table.Name))
-	} else {
-		logger.Debug("Table schema is up to date",
+		updateDescriptions(ctx, tableRef, metadata, table, logger)
+		return table.Name, nil
+	}
+
+	diff := model.DiffSchemas(metadata.Schema, table.Schema)
+	if diff.IsEmpty() {
+		logger.Debug("Existing table schema can load the source schema without changes",
 			zap.String("dataset", datasetID),
 			zap.String("table", table.Name))
+		updateDescriptions(ctx, tableRef, metadata, table, logger)
+		return table.Name, nil
+	}
+
+	logger.Warn("Schema change detected",
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name),
+		zap.String("schema_policy", policy),
+		zap.String("diff", diff.Summary()))
+
+	if policy == model.SchemaPolicyFail {
+		return "", fmt.Errorf("schema of table '%s' changed (%s) and schema policy is %q", table.Name, diff.Summary(), policy)
+	}
+
+	if !diff.IsCompatible() {
+		switch policy {
+		case model.SchemaPolicyRecreate:
+			return table.Name, recreateTable(ctx, tableRef, datasetID, table, logger)
+		case model.SchemaPolicyQuarantine:
+			return quarantineTable(ctx, client, datasetID, table, diff, logger)
+		default:
+			return "", fmt.Errorf("incompatible schema change for table '%s' cannot be applied in place (%s)", table.Name, diff.Summary())
+		}
+	}
+
+	if err := evolveTable(ctx, client, tableRef, metadata, table, diff, logger); err != nil {
+		return "", err
+	}
+	return table.Name, nil
+}
+
+// createTable creates a BigQuery table with the given schema.
+func createTable(ctx context.Context, tableRef *bigquery.Table, datasetID string, table model.BQTable, logger *zap.Logger) error {
+	logger.Info("Table not found, creating new table",
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name),
+		zap.Int("schema_fields", len(table.Schema)))
+
+	err := tableRef.Create(ctx, &bigquery.TableMetadata{
+		Name:             table.Name,
+		Schema:           table.Schema,
+		TimePartitioning: table.TimePartitioning,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create table '%s': %w", table.Name, err)
 	}
 
+	logger.Info("Table created successfully",
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name))
 	return nil
 }
 
+// updateDescriptions copies changed source column comments to the field descriptions of an existing
+// table whose schema needs no other change. A failure only leaves the descriptions stale.
+func updateDescriptions(ctx context.Context, tableRef *bigquery.Table, metadata *bigquery.TableMetadata, table model.BQTable, logger *zap.Logger) {
+	described, changed := model.DescribeSchema(metadata.Schema, table.Schema)
+	if !changed {
+		return
+	}
+	if _, err := tableRef.Update(ctx, bigquery.TableMetadataToUpdate{Schema: described}, metadata.ETag); err != nil {
+		logger.Warn("Failed to update column descriptions",
+			zap.String("table", table.Name),
+			zap.Error(err))
+		return
+	}
+	logger.Info("Updated column descriptions from source column comments",
+		zap.String("table", table.Name))
+}
+
+// evolveTable applies compatible schema changes in place without touching existing data: new columns
+// are added, REQUIRED columns are relaxed, dropped columns are kept as NULLABLE, and numeric columns
+// and parameterized types (STRING(L), NUMERIC(P,S)) are widened with ALTER COLUMN SET DATA TYPE.
+func evolveTable(ctx context.Context, client *bigquery.Client, tableRef *bigquery.Table, metadata *bigquery.TableMetadata, table model.BQTable, diff *model.SchemaDiff, logger *zap.Logger) error {
+	if len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Relaxed) > 0 {
+		update := bigquery.TableMetadataToUpdate{Schema: model.EvolveSchema(metadata.Schema, table.Schema)}
+		if _, err := tableRef.Update(ctx, update, metadata.ETag); err != nil {
+			return fmt.Errorf("failed to update table schema for '%s': %w", table.Name, err)
+		}
+	}
+
+	for _, change := range diff.Widened {
+		sql := fmt.Sprintf("ALTER TABLE `%s.%s.%s` ALTER COLUMN %s SET DATA TYPE %s",
+			tableRef.ProjectID, tableRef.DatasetID, tableRef.TableID,
+			quoteBigQueryIdentifier(change.Name), standardSQLTypeName(change.To)+change.ToParams)
+		if err := runBigQueryStatement(ctx, client, sql, nil); err != nil {
+			return fmt.Errorf("failed to widen column '%s' of table '%s' from %s%s to %s%s: %w",
+				change.Name, table.Name, change.From, change.FromParams, change.To, change.ToParams, err)
+		}
+	}
+
+	logger.Info("Table schema evolved successfully",
+		zap.String("dataset", tableRef.DatasetID),
+		zap.String("table", table.Name),
+		zap.Strings("added", diff.Added),
+		zap.Strings("kept_dropped", diff.Removed),
+		zap.Strings("relaxed", diff.Relaxed),
+		zap.Int("widened", len(diff.Widened)))
+	return nil
+}
+
+// recreateTable drops a table with an incompatible schema and creates it again with the new schema.
+// All existing data in the table is lost.
+func recreateTable(ctx context.Context, tableRef *bigquery.Table, datasetID string, table model.BQTable, logger *zap.Logger) error {
+	logger.Warn("Recreating table will delete all existing data",
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name))
+
+	if err := tableRef.Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete table '%s' with incompatible schema: %w", table.Name, err)
+	}
+	logger.Info("Table deleted",
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name))
+
+	if err := createTable(ctx, tableRef, datasetID, table, logger); err != nil {
+		return fmt.Errorf("failed to recreate table '%s' with new schema: %w", table.Name, err)
+	}
+	return nil
+}
+
+// quarantineTable leaves a table with an incompatible schema untouched and prepares a versioned table,
+// named after a fingerprint of the new schema, to load into instead. Runs with the same incompatible
+// schema keep loading into the same versioned table until the conflict is resolved.
+func quarantineTable(ctx context.Context, client *bigquery.Client, datasetID string, table model.BQTable, diff *model.SchemaDiff, logger *zap.Logger) (string, error) {
+	versioned := model.BQTable{
+		Name:             fmt.Sprintf("%s__quarantine_%s", table.Name, model.SchemaFingerprint(table.Schema)),
+		Schema:           table.Schema,
+		TimePartitioning: table.TimePartitioning,
+	}
+
+	logger.Error("ALERT: incompatible schema change quarantined, loading into versioned table",
+		zap.Bool("alert", true),
+		zap.String("dataset", datasetID),
+		zap.String("table", table.Name),
+		zap.String("quarantine_table", versioned.Name),
+		zap.String("diff", diff.Summary()))
+
+	if _, err := createOrUpdateTable(ctx, client, datasetID, versioned, model.SchemaPolicyEvolve, logger); err != nil {
+		return "", fmt.Errorf("failed to prepare quarantine table '%s': %w", versioned.Name, err)
+	}
+	return versioned.Name, nil
+}
+
+// standardSQLTypeName returns the GoogleSQL type name used in DDL for a BigQuery field type.
+func standardSQLTypeName(t bigquery.FieldType) string {
+	switch t {
+	case bigquery.IntegerFieldType:
+		return "INT64"
+	case bigquery.FloatFieldType:
+		return "FLOAT64"
+	case bigquery.BooleanFieldType:
+		return "BOOL"
+	default:
+		return string(t)
+	}
+}
+
+// isNotFoundError reports whether a BigQuery API error indicates a missing table or dataset.
+func isNotFoundError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "Not found") || strings.Contains(err.Error(), "notFound"))
+}
+
+// isAlreadyExistsError reports whether a BigQuery API error indicates that a resource already exists.
+func isAlreadyExistsError(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), "Already Exists") || strings.Contains(err.Error(), "duplicate"))
+}
+
+// bigQueryTableRef returns the fully-qualified, backtick-quoted reference to a table in the
+// configured dataset, for use in BigQuery SQL statements.
+func bigQueryTableRef(cfg *model.Config, table string) string {
+	return fmt.Sprintf("`%s.%s.%s`", cfg.GCPProjectID, cfg.BigQueryDatasetID, table)
+}
+
+// runBigQueryStatement runs a BigQuery SQL statement (DML, DDL or a script) as a query job
+// and waits for it to complete.
+func runBigQueryStatement(ctx context.Context, client *bigquery.Client, sql string, params []bigquery.QueryParameter) error {
+	return runBigQueryStatementWithJobID(ctx, client, sql, params, "")
+}
+
+// runBigQueryStatementWithJobID runs a BigQuery SQL statement as a query job with the given job ID,
+// or an ID BigQuery generates if it is empty, and waits for it to complete.
+func runBigQueryStatementWithJobID(ctx context.Context, client *bigquery.Client, sql string, params []bigquery.QueryParameter, jobID string) error {
+	q := client.Query(sql)
+	q.Parameters = params
+	q.JobID = jobID
+
+	bqJob, err := q.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery query job: %w", err)
+	}
+	return waitForBigQueryStatement(ctx, bqJob)
+}
+
+// waitForBigQueryStatement waits for the query job of a statement to complete and returns its error, if any.
+func waitForBigQueryStatement(ctx context.Context, bqJob *bigquery.Job) error {
+	status, err := bqJob.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for BigQuery job: %w", err)
+	}
+
+	if stErr := status.Err(); stErr != nil {
+		return fmt.Errorf("BigQuery query job failed: %w.%s", stErr, formatBigQueryStatusErrors(status))
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// maxFieldDescriptionLength is the longest field description BigQuery accepts, in characters.
+const maxFieldDescriptionLength = 1024
+
+// catalogColumn is a column of a source table as declared in the database catalog.
+type catalogColumn struct {
+	Name      string
+	Type      string // Type name as the type mappers expect it, e.g. DECIMAL or CHARACTER VARYING
+	Array     bool   // PostgreSQL array, Type is then the element type
+	TypeOID   uint32 // PostgreSQL type (or element type) OID
+	UserType  bool   // PostgreSQL enum, composite or domain type, resolved by TypeOID
+	Nullable  bool
+	Precision int64 // Declared precision of a decimal column (0 = unconstrained)
+	Scale     int64 // Declared scale of a decimal column
+	Length    int64 // Declared length of a character column (0 = unlimited)
+	Comment   string
+}
+
+// mysqlIntegerTypes lists the MySQL integer types the driver reports as UNSIGNED ... when unsigned.
+var mysqlIntegerTypes = map[string]bool{
+	"TINYINT": true, "SMALLINT": true, "MEDIUMINT": true, "INT": true, "BIGINT": true,
+}
+
+const mysqlCatalogQuery = `SELECT COLUMN_NAME, DATA_TYPE, COLUMN_TYPE, IS_NULLABLE = 'YES',
+	COALESCE(NUMERIC_PRECISION, 0), COALESCE(NUMERIC_SCALE, 0), COALESCE(CHARACTER_MAXIMUM_LENGTH, 0), COLUMN_COMMENT
+	FROM information_schema.COLUMNS
+	WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ?
+	ORDER BY ORDINAL_POSITION`
+
+// postgresCatalogQuery describes the columns of a table from pg_attribute. Columns of a domain
+// type are described by the domain's base type; precision, scale and length are decoded from the
+// type modifier, which for an array column applies to its elements. Enum and composite types, and
+// domains of array elements, are flagged to be resolved through pg_type.
+const postgresCatalogQuery = `SELECT a.attname, format_type(b.oid, -1), b.typcategory = 'A', e.oid, et.typtype IN ('d', 'e', 'c'),
+	NOT a.attnotnull,
+	CASE WHEN e.oid = 'numeric'::regtype AND m.typmod >= 4 THEN ((m.typmod - 4) >> 16) & 65535 ELSE 0 END,
+	CASE WHEN e.oid = 'numeric'::regtype AND m.typmod >= 4 THEN (m.typmod - 4) & 65535 ELSE 0 END,
+	CASE WHEN e.oid IN ('varchar'::regtype, 'bpchar'::regtype) AND m.typmod >= 4 THEN m.typmod - 4 ELSE 0 END,
+	COALESCE(col_description(a.attrelid, a.attnum), '')
+	FROM pg_catalog.pg_attribute a
+	JOIN pg_catalog.pg_class c ON c.oid = a.attrelid
+	JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+	CROSS JOIN LATERAL (SELECT
+		CASE WHEN t.typtype = 'd' THEN t.typbasetype ELSE t.oid END AS oid,
+		CASE WHEN t.typtype = 'd' THEN t.typtypmod ELSE a.atttypmod END AS typmod) m
+	JOIN pg_catalog.pg_type b ON b.oid = m.oid
+	CROSS JOIN LATERAL (SELECT CASE WHEN b.typcategory = 'A' THEN b.typelem ELSE b.oid END AS oid) e
+	JOIN pg_catalog.pg_type et ON et.oid = e.oid
+	WHERE n.nspname = $1 AND c.relname = $2 AND a.attnum > 0 AND NOT a.attisdropped
+	ORDER BY a.attnum`
+
+// readCatalogColumns returns the columns of the source table in their declared order, or none if
+// the catalog does not describe the table or the database type has no catalog lookup.
+func readCatalogColumns(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig) ([]catalogColumn, error) {
+	schemaName, tableName, err := sourceSchemaAndTable(dbConfig, tableConfig)
+	if err != nil {
+		return nil, err
+	}
+	dbType := strings.ToLower(dbConfig.Type)
+
+	var query string
+	switch dbType {
+	case "mysql":
+		query = mysqlCatalogQuery
+	case "postgres":
+		query = postgresCatalogQuery
+	default:
+		return nil, nil
+	}
+	rows, err := db.QueryContext(ctx, query, schemaName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of '%s.%s' from the catalog: %w", schemaName, tableName, err)
+	}
+	defer rows.Close()
+
+	var columns []catalogColumn
+	for rows.Next() {
+		var col catalogColumn
+		if dbType == "postgres" {
+			err = rows.Scan(&col.Name, &col.Type, &col.Array, &col.TypeOID, &col.UserType, &col.Nullable, &col.Precision, &col.Scale, &col.Length, &col.Comment)
+			col.Type = strings.ToUpper(col.Type)
+			if col.Array {
+				col.Type = strings.TrimSuffix(col.Type, "[]")
+			}
+		} else {
+			var columnType string
+			err = rows.Scan(&col.Name, &col.Type, &columnType, &col.Nullable, &col.Precision, &col.Scale, &col.Length, &col.Comment)
+			col.Type = strings.ToUpper(col.Type)
+			if mysqlIntegerTypes[col.Type] && strings.Contains(strings.ToLower(columnType), "unsigned") {
+				// Named as the driver reports the column types of a query, so that the schema
+				// inferred for an existing table does not change.
+				col.Type = "UNSIGNED " + col.Type
+			}
+			if col.Type == "BIT" {
+				// The width decides between BOOLEAN and INTEGER.
+				col.Type = fmt.Sprintf("BIT(%d)", col.Precision)
+			}
+			if col.Type != "CHAR" && col.Type != "VARCHAR" {
+				col.Length = 0
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of '%s.%s' from the catalog: %w", schemaName, tableName, err)
+		}
+		if col.Type != "DECIMAL" && col.Type != "NUMERIC" {
+			col.Precision, col.Scale = 0, 0
+		}
+		columns = append(columns, col)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read columns of '%s.%s' from the catalog: %w", schemaName, tableName, err)
+	}
+	return columns, nil
+}
+
+// InferSchemaFromCatalog builds the BigQuery schema of a source table from the database catalog
+// (information_schema.COLUMNS on MySQL, pg_attribute on PostgreSQL), which unlike the column types
+// of a query keeps declared decimal precision and scale, character lengths and column comments.
+// Only the configured COLUMNS are included, in their configured order. It returns a nil schema if
+// the catalog does not describe the table.
+func InferSchemaFromCatalog(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, logger *zap.Logger) (bigquery.Schema, error) {
+	columns, err := readCatalogColumns(ctx, db, dbConfig, tableConfig)
+	if err != nil || len(columns) == 0 {
+		return nil, err
+	}
+	columns, err = selectCatalogColumns(dbConfig, columns, tableConfig.Columns)
+	if err != nil {
+		return nil, err
+	}
+
+	typeMapper := mysqlTypeToBigQueryType
+	if strings.EqualFold(dbConfig.Type, "postgres") {
+		typeMapper = postgresTypeToBigQueryType
+	}
+
+	schema := make(bigquery.Schema, 0, len(columns))
+	for _, col := range columns {
+		if err := validateBigQueryIdentifier(col.Name, "Source column name"); err != nil {
+			return nil, err
+		}
+
+		field := &bigquery.FieldSchema{
+			Name:        col.Name,
+			Required:    !col.Nullable && !col.Array, // REPEATED fields cannot be REQUIRED
+			Repeated:    col.Array,
+			Description: truncateDescription(col.Comment),
+		}
+		if col.UserType {
+			if err := resolvePostgresType(ctx, db, field, col.TypeOID, -1, 1, logger); err != nil {
+				return nil, err
+			}
+		} else {
+			field.Type = typeMapper(col.Type, logger)
+		}
+		switch {
+		case col.Type == "DECIMAL" || col.Type == "NUMERIC":
+			applyDecimalType(field, col, logger)
+		case field.Type == bigquery.StringFieldType && col.Length > 0:
+			field.MaxLength = col.Length
+		}
+		schema = append(schema, field)
+
+		logger.Debug("Mapped catalog column to BigQuery field",
+			zap.String("database", dbConfig.Name),
+			zap.String("column_name", col.Name),
+			zap.String("source_type", col.Type),
+			zap.String("bigquery_type", string(field.Type)+model.TypeParameters(field)),
+			zap.Bool("required", field.Required),
+			zap.Bool("repeated", field.Repeated),
+		)
+	}
+
+	logger.Info("Catalog schema inference complete",
+		zap.String("database", dbConfig.Name),
+		zap.Int("fields_mapped", len(schema)))
+	return schema, nil
+}
+
+// selectCatalogColumns returns the catalog columns named in selected, in that order, or all columns
+// if selected is empty. Unquoted MySQL column names keep the spelling of the query in its result,
+// so they keep the configured spelling here too.
+func selectCatalogColumns(dbConfig *model.DatabaseConfig, columns []catalogColumn, selected []string) ([]catalogColumn, error) {
+	if len(selected) == 0 {
+		return columns, nil
+	}
+	picked := make([]catalogColumn, 0, len(selected))
+	for _, name := range selected {
+		found := false
+		for _, col := range columns {
+			if strings.EqualFold(col.Name, name) {
+				if strings.EqualFold(dbConfig.Type, "mysql") {
+					col.Name = name
+				}
+				picked = append(picked, col)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("column '%s' not found in source table", name)
+		}
+	}
+	return picked, nil
+}
+
+// applyDecimalType maps a decimal column to NUMERIC when its declared precision and scale fit
+// (at most 29 integer and 9 fractional digits), otherwise to BIGNUMERIC (at most 38 of each),
+// keeping the declared precision and scale. An unconstrained PostgreSQL numeric maps to
+// BIGNUMERIC, as its values may have any scale.
+func applyDecimalType(field *bigquery.FieldSchema, col catalogColumn, logger *zap.Logger) {
+	integerDigits := col.Precision - col.Scale
+	switch {
+	case col.Precision <= 0 || col.Scale < 0 || integerDigits < 0:
+		field.Type = bigquery.BigNumericFieldType
+	case col.Scale <= 9 && integerDigits <= 29:
+		field.Type = bigquery.NumericFieldType
+		field.Precision, field.Scale = col.Precision, col.Scale
+	case col.Scale <= 38 && integerDigits <= 38:
+		field.Type = bigquery.BigNumericFieldType
+		field.Precision, field.Scale = col.Precision, col.Scale
+	default:
+		logger.Warn("Decimal column exceeds the range of BIGNUMERIC, values out of range will fail to load",
+			zap.String("column_name", col.Name),
+			zap.Int64("precision", col.Precision),
+			zap.Int64("scale", col.Scale))
+		field.Type = bigquery.BigNumericFieldType
+	}
+}
+
+// truncateDescription cuts a column comment to the longest description BigQuery accepts.
+func truncateDescription(comment string) string {
+	comment = strings.TrimSpace(comment)
+	if runes := []rune(comment); len(runes) > maxFieldDescriptionLength {
+		return string(runes[:maxFieldDescriptionLength])
+	}
+	return comment
+}
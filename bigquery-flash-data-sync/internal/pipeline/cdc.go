@@ -0,0 +1,341 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// Columns added to the staged change records of a CDC table.
+const (
+	changeOpColumn  = "_change_op"  // insert, update or delete
+	changeTSColumn  = "_change_ts"  // Commit timestamp of the source transaction
+	changeSeqColumn = "_change_seq" // Order of the change within the micro-batch
+)
+
+// Operations of a change record.
+const (
+	changeInsert = "insert"
+	changeUpdate = "update"
+	changeDelete = "delete"
+)
+
+// changeStagingSchema returns the schema of the staging table change records of a table are loaded into.
+func changeStagingSchema(schema bigquery.Schema) bigquery.Schema {
+	staging := make(bigquery.Schema, 0, len(schema)+3)
+	for _, field := range schema {
+		f := *field
+		// Deletes may only carry the primary key, so no source column is required in a change record.
+		f.Required = false
+		staging = append(staging, &f)
+	}
+	return append(staging,
+		&bigquery.FieldSchema{Name: changeOpColumn, Type: bigquery.StringFieldType, Required: true},
+		&bigquery.FieldSchema{Name: changeTSColumn, Type: bigquery.TimestampFieldType},
+		&bigquery.FieldSchema{Name: changeSeqColumn, Type: bigquery.IntegerFieldType, Required: true},
+	)
+}
+
+// changeApplier applies the change records of one table to its target table in micro-batches:
+// records are streamed into a staging table, and every batchSize records (at a transaction
+// boundary chosen by the caller) the staging table is merged into the target and the change log
+// position the batch ends at is saved. A failed micro-batch leaves the stored position where it
+// was, so the next run reads its changes again; the merge applies only the latest change per key,
+// which makes replaying them harmless.
+type changeApplier struct {
+	ctx       context.Context
+	client    *bigquery.Client
+	cfg       *model.Config
+	job       model.Job // Template of the load job of every micro-batch
+	schema    bigquery.Schema
+	keys      []string
+	batchSize int
+	retry     *retrier
+	logger    *zap.Logger
+
+	stream  *loadStream
+	pending int
+	seq     int64
+	applied int64
+	batches int
+}
+
+// newChangeApplier creates the applier of a table. job describes the staging table (LoadTable)
+// and the target table (TargetTable) of the change records.
+func newChangeApplier(ctx context.Context, client *bigquery.Client, cfg *model.Config, job model.Job, schema bigquery.Schema, keys []string, batchSize int, retry *retrier, logger *zap.Logger) *changeApplier {
+	// Every micro-batch replaces the contents of the staging table with its first load job.
+	job.TruncateFirstLoad = true
+	job.LoadJobIDPrefix = ""
+	return &changeApplier{
+		ctx:       ctx,
+		client:    client,
+		cfg:       cfg,
+		job:       job,
+		schema:    schema,
+		keys:      keys,
+		batchSize: batchSize,
+		retry:     retry,
+		logger:    logger,
+	}
+}
+
+// Add stages one change record. row holds the source columns of the record.
+func (a *changeApplier) Add(op string, committedAt time.Time, row map[string]any) error {
+	if a.stream == nil {
+		a.stream = newLoadStream(a.ctx, a.client, a.cfg.BigQueryDatasetID, a.job, a.logger)
+	}
+
+	a.seq++
+	row[changeOpColumn] = op
+	row[changeSeqColumn] = a.seq
+	if !committedAt.IsZero() {
+		row[changeTSColumn] = committedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	if err := a.stream.Write(row); err != nil {
+		return fmt.Errorf("failed to stage %s change: %w", op, err)
+	}
+	a.pending++
+	return nil
+}
+
+// Full reports whether the current micro-batch reached the batch size and should be flushed
+// at the next transaction boundary.
+func (a *changeApplier) Full() bool {
+	return a.batchSize > 0 && a.pending >= a.batchSize
+}
+
+// Flush completes the current micro-batch: it merges the staged changes into the target table,
+// then calls savePosition to record the change log position the batch ends at.
+func (a *changeApplier) Flush(savePosition func(ctx context.Context) error) error {
+	if a.stream != nil {
+		err := a.stream.Close()
+		a.stream = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	if a.pending > 0 {
+		err := a.retry.do(a.ctx, "merge changes", func(ctx context.Context) error {
+			return mergeChangesIntoTarget(ctx, a.client, a.cfg, a.job.LoadTable, a.job.TargetTable, a.schema, a.keys, a.logger)
+		})
+		if err != nil {
+			return err
+		}
+		a.batches++
+		a.applied += int64(a.pending)
+		a.logger.Info("Applied change batch to target table",
+			zap.Int("changes", a.pending),
+			zap.Int("batch", a.batches),
+			zap.Int64("changes_applied_total", a.applied))
+	}
+
+	if err := a.retry.do(a.ctx, "save change log position", savePosition); err != nil {
+		return err
+	}
+	a.pending = 0
+	a.seq = 0
+	return nil
+}
+
+// Abort discards the current micro-batch.
+func (a *changeApplier) Abort(cause error) {
+	if a.stream != nil {
+		a.stream.Abort(cause)
+		a.stream = nil
+	}
+}
+
+// Applied returns the number of change records merged into the target table.
+func (a *changeApplier) Applied() int64 {
+	return a.applied
+}
+
+// mergeChangesIntoTarget applies the change records of a staging table to the target table:
+// the latest change of every key wins, deletes remove the target row, inserts and updates upsert it.
+func mergeChangesIntoTarget(ctx context.Context, client *bigquery.Client, cfg *model.Config, staging, target string, schema bigquery.Schema, keys []string, logger *zap.Logger) error {
+	orderBy := quoteBigQueryIdentifier(changeSeqColumn) + " DESC"
+	sql, err := buildMergeStatement(cfg, staging, target, schema, keys, orderBy, changeOpColumn)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Merging change records into target",
+		zap.String("staging_table", staging),
+		zap.Strings("primary_key", keys),
+		zap.String("statement", sql))
+
+	if err := runBigQueryStatement(ctx, client, sql, nil); err != nil {
+		return fmt.Errorf("failed to merge change records of '%s' into '%s': %w", staging, target, err)
+	}
+	return nil
+}
+
+// changeSink receives the row changes a change log reader decodes for a table. It converts their
+// values, buffers the changes of the open transaction and stages them in the table's applier when
+// the transaction commits, so a micro-batch always ends at a transaction boundary.
+type changeSink struct {
+	applier   *changeApplier
+	converter *model.RowConverter
+	keys      []string
+	txn       []pendingChange
+}
+
+// pendingChange is a row change read from a transaction that has not committed yet.
+type pendingChange struct {
+	op  string
+	row map[string]any
+}
+
+// newChangeSink creates the staging table the change records of a table are loaded into and the
+// sink that applies them to the target table. The returned function drops the staging table.
+func newChangeSink(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, targetTable string, retry *retrier, logger *zap.Logger) (*changeSink, func(), error) {
+	staging := stagingTableName(targetTable, "changes", runID)
+	err := retry.do(ctx, "create staging table", func(ctx context.Context) error {
+		return createStagingTable(ctx, bqClient, cfg.BigQueryDatasetID, staging, changeStagingSchema(schema), logger)
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create change staging table: %w", err)
+	}
+	drop := func() {
+		dropStagingTable(context.WithoutCancel(ctx), bqClient, cfg.BigQueryDatasetID, staging, logger)
+	}
+
+	keys := tableConfig.GetPrimaryKeyColumns()
+	return &changeSink{
+		applier: newChangeApplier(ctx, bqClient, cfg, model.Job{
+			Name:         tableConfig.Name,
+			DatabaseName: dbConfig.Name,
+			DatabaseType: dbConfig.Type,
+			SourceTable:  tableConfig.Name,
+			TargetTable:  targetTable,
+			LoadTable:    staging,
+			RunID:        runID,
+			MaxLoadBytes: cfg.LoadJobMaxBytes,
+		}, schema, keys, tableConfig.CDCBatchSize, retry, logger),
+		converter: model.NewRowConverter(schema, tableConfig.InvalidValuePolicy, cfg.DateFormat, logger),
+		keys:      keys,
+	}, drop, nil
+}
+
+// convert converts a source value of a column for its BigQuery field. A skipped change would
+// silently diverge the table, so any invalid value fails the sync.
+func (s *changeSink) convert(column string, val any) (any, error) {
+	return s.converter.Convert(column, val)
+}
+
+// begin discards the changes buffered so far and starts buffering a new transaction.
+func (s *changeSink) begin() {
+	s.txn = s.txn[:0]
+}
+
+// insert buffers the insert of row.
+func (s *changeSink) insert(row map[string]any) {
+	s.txn = append(s.txn, pendingChange{op: changeInsert, row: row})
+}
+
+// delete buffers the delete of row.
+func (s *changeSink) delete(row map[string]any) {
+	s.txn = append(s.txn, pendingChange{op: changeDelete, row: row})
+}
+
+// update buffers the update of a row to after. An update that changes the primary key is recorded
+// as a delete of the old key followed by an update of the new one; before is the old row image, or
+// nil if the change log did not record it because the key is unchanged.
+func (s *changeSink) update(before, after map[string]any) {
+	if before != nil && !sameKey(s.keys, before, after) {
+		s.delete(before)
+	}
+	s.txn = append(s.txn, pendingChange{op: changeUpdate, row: after})
+}
+
+// commit stages the buffered changes of the transaction, which committed at committedAt.
+func (s *changeSink) commit(committedAt time.Time) error {
+	for _, change := range s.txn {
+		if err := s.applier.Add(change.op, committedAt, change.row); err != nil {
+			return err
+		}
+	}
+	s.txn = s.txn[:0]
+	return nil
+}
+
+// sameKey reports whether two row images have the same primary key.
+func sameKey(keys []string, a, b map[string]any) bool {
+	for _, key := range keys {
+		if fmt.Sprint(a[key]) != fmt.Sprint(b[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sourceSchemaAndTable returns the schema (PostgreSQL) or database (MySQL) and the name of the
+// source table, as they appear in the source's change log.
+func sourceSchemaAndTable(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig) (string, string, error) {
+	schemaOrDB, table, hasQualifier, err := splitQualifiedName(tableConfig.Name)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid table name: %w", err)
+	}
+	if !hasQualifier {
+		schemaOrDB = dbConfig.DatabaseName
+	}
+	return schemaOrDB, table, nil
+}
+
+// changeLogStartPosition returns the position in the source's change log from which changes must
+// be applied after a full copy of the table taken from now on, in its stored form. For PostgreSQL
+// it creates the table's publication and replication slot, which retain the changes from then on.
+func changeLogStartPosition(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, logger *zap.Logger) (string, error) {
+	switch strings.ToLower(dbConfig.Type) {
+	case "mysql":
+		if err := checkBinlogFormat(ctx, db); err != nil {
+			return "", err
+		}
+		position, err := currentBinlogPosition(ctx, db)
+		if err != nil {
+			return "", err
+		}
+		return position.String(), nil
+	case "postgres":
+		return ensureReplicationSlot(ctx, db, dbConfig, tableConfig, logger)
+	default:
+		return "", fmt.Errorf("change data capture is not supported for database type '%s'", dbConfig.Type)
+	}
+}
+
+// syncChanges applies the changes logged in the source's change log for a table since the stored
+// position from. Returns the number of change records applied.
+func syncChanges(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, targetTable, from string, retry *retrier, logger *zap.Logger) (int64, error) {
+	switch strings.ToLower(dbConfig.Type) {
+	case "mysql":
+		return syncBinlogChanges(ctx, bqClient, cfg, runID, db, dbConfig, tableConfig, schema, targetTable, from, retry, logger)
+	case "postgres":
+		return syncWALChanges(ctx, bqClient, cfg, runID, db, dbConfig, tableConfig, schema, targetTable, from, retry, logger)
+	default:
+		return 0, fmt.Errorf("change data capture is not supported for database type '%s'", dbConfig.Type)
+	}
+}
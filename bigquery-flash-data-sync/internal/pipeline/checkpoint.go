@@ -0,0 +1,302 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+)
+
+// checkpointSchema is the schema of the checkpoint table kept in the target dataset.
+// There is one row per (database_name, source_table, run_id, chunk) of an unfinished table sync.
+var checkpointSchema = bigquery.Schema{
+	{Name: "database_name", Type: bigquery.StringFieldType, Required: true},
+	{Name: "source_table", Type: bigquery.StringFieldType, Required: true},
+	{Name: "run_id", Type: bigquery.StringFieldType, Required: true},
+	{Name: "chunk", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "range_lower", Type: bigquery.IntegerFieldType},
+	{Name: "range_upper", Type: bigquery.IntegerFieldType},
+	{Name: "window_to", Type: bigquery.TimestampFieldType},
+	{Name: "last_primary_key", Type: bigquery.StringFieldType},
+	{Name: "batches_committed", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "rows_committed", Type: bigquery.IntegerFieldType, Required: true},
+	{Name: "completed", Type: bigquery.BooleanFieldType, Required: true},
+	{Name: "updated_at", Type: bigquery.TimestampFieldType, Required: true},
+}
+
+// tableCheckpoint is the saved progress of one chunk of an unfinished table sync.
+type tableCheckpoint struct {
+	RunID            string                 `bigquery:"run_id"`
+	Chunk            int64                  `bigquery:"chunk"`
+	RangeLower       bigquery.NullInt64     `bigquery:"range_lower"`
+	RangeUpper       bigquery.NullInt64     `bigquery:"range_upper"`
+	WindowTo         bigquery.NullTimestamp `bigquery:"window_to"`
+	LastPrimaryKey   bigquery.NullString    `bigquery:"last_primary_key"`
+	BatchesCommitted int64                  `bigquery:"batches_committed"`
+	RowsCommitted    int64                  `bigquery:"rows_committed"`
+	Completed        bool                   `bigquery:"completed"`
+}
+
+// KeyRange returns the primary key range of the chunk, or nil when the table was not chunked.
+func (c *tableCheckpoint) KeyRange() *keyRange {
+	if !c.RangeLower.Valid && !c.RangeUpper.Valid {
+		return nil
+	}
+	return &keyRange{
+		Lower:    c.RangeLower.Int64,
+		HasLower: c.RangeLower.Valid,
+		Upper:    c.RangeUpper.Int64,
+		HasUpper: c.RangeUpper.Valid,
+	}
+}
+
+// LastKey returns the primary key values of the last row loaded by the chunk, or nil when
+// no load job of the chunk has committed yet. Integer keys are returned as int64, so they are
+// bound as integers: MySQL compares an integer column with a string parameter as DOUBLE, which
+// is inexact above 2^53.
+func (c *tableCheckpoint) LastKey() ([]any, error) {
+	if !c.LastPrimaryKey.Valid {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(c.LastPrimaryKey.StringVal))
+	dec.UseNumber()
+	var key []any
+	if err := dec.Decode(&key); err != nil {
+		return nil, fmt.Errorf("invalid last_primary_key %q in checkpoint: %w", c.LastPrimaryKey.StringVal, err)
+	}
+	for i, v := range key {
+		n, ok := v.(json.Number)
+		if !ok {
+			continue
+		}
+		if integer, err := n.Int64(); err == nil {
+			key[i] = integer
+		} else if f, err := n.Float64(); err == nil {
+			key[i] = f
+		} else {
+			key[i] = n.String()
+		}
+	}
+	return key, nil
+}
+
+// ensureCheckpointTable creates the checkpoint table if it does not exist yet.
+func ensureCheckpointTable(ctx context.Context, client *bigquery.Client, cfg *model.Config, logger *zap.Logger) error {
+	_, err := createOrUpdateTable(ctx, client, cfg.BigQueryDatasetID, model.BQTable{
+		Name:   cfg.CheckpointTable,
+		Schema: checkpointSchema,
+	}, model.SchemaPolicyEvolve, logger)
+	return err
+}
+
+// loadCheckpoints reads the chunk checkpoints of the most recent unfinished sync of a table,
+// ordered by chunk. Returns nil when there is nothing to resume.
+func loadCheckpoints(ctx context.Context, client *bigquery.Client, cfg *model.Config, dbName, tableName string) ([]tableCheckpoint, error) {
+	ref := bigQueryTableRef(cfg, cfg.CheckpointTable)
+	q := client.Query(fmt.Sprintf(`SELECT run_id, chunk, range_lower, range_upper, window_to, last_primary_key,
+  batches_committed, rows_committed, completed
+FROM %s
+WHERE database_name = @database_name AND source_table = @source_table
+  AND run_id = (
+    SELECT run_id FROM %s
+    WHERE database_name = @database_name AND source_table = @source_table
+    ORDER BY updated_at DESC LIMIT 1)
+ORDER BY chunk`, ref, ref))
+	q.Parameters = stateKeyParams(dbName, tableName)
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+
+	var checkpoints []tableCheckpoint
+	for {
+		var cp tableCheckpoint
+		err := it.Next(&cp)
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	return checkpoints, nil
+}
+
+// checkpointer saves the progress of the chunks of one table sync so that an interrupted
+// sync can be continued by a --resume run.
+type checkpointer struct {
+	client     *bigquery.Client
+	cfg        *model.Config
+	dbName     string
+	tableName  string
+	runID      string
+	keyColumns []string
+}
+
+// newCheckpointer creates the checkpointer of a table sync. keyColumns are the primary key
+// columns the extraction is ordered by.
+func newCheckpointer(client *bigquery.Client, cfg *model.Config, dbName, tableName, runID string, keyColumns []string) *checkpointer {
+	return &checkpointer{
+		client:     client,
+		cfg:        cfg,
+		dbName:     dbName,
+		tableName:  tableName,
+		runID:      runID,
+		keyColumns: keyColumns,
+	}
+}
+
+// Begin replaces the checkpoints of earlier syncs of the table with one row per chunk of this sync.
+// window is the incremental window of the sync, if any, which a resumed sync must keep.
+func (c *checkpointer) Begin(ctx context.Context, plans []chunkPlan, window *watermarkWindow) error {
+	var windowTo bigquery.NullTimestamp
+	if window != nil {
+		windowTo = bigquery.NullTimestamp{Timestamp: window.To, Valid: true}
+	}
+
+	params := append(c.keyParams(),
+		bigquery.QueryParameter{Name: "run_id", Value: c.runID},
+		bigquery.QueryParameter{Name: "window_to", Value: windowTo},
+	)
+	values := make([]string, len(plans))
+	for i, plan := range plans {
+		var lower, upper bigquery.NullInt64
+		if plan.Keys != nil {
+			lower = bigquery.NullInt64{Int64: plan.Keys.Lower, Valid: plan.Keys.HasLower}
+			upper = bigquery.NullInt64{Int64: plan.Keys.Upper, Valid: plan.Keys.HasUpper}
+		}
+		values[i] = fmt.Sprintf("(@database_name, @source_table, @run_id, %d, @range_lower_%d, @range_upper_%d, @window_to, NULL, 0, 0, FALSE, CURRENT_TIMESTAMP())",
+			plan.Index, i, i)
+		params = append(params,
+			bigquery.QueryParameter{Name: fmt.Sprintf("range_lower_%d", i), Value: lower},
+			bigquery.QueryParameter{Name: fmt.Sprintf("range_upper_%d", i), Value: upper},
+		)
+	}
+
+	ref := bigQueryTableRef(c.cfg, c.cfg.CheckpointTable)
+	sql := fmt.Sprintf(`DELETE FROM %s WHERE database_name = @database_name AND source_table = @source_table;
+INSERT INTO %s (database_name, source_table, run_id, chunk, range_lower, range_upper, window_to,
+  last_primary_key, batches_committed, rows_committed, completed, updated_at)
+VALUES %s;`, ref, ref, strings.Join(values, ",\n  "))
+
+	if err := runBigQueryStatement(ctx, c.client, sql, params); err != nil {
+		return fmt.Errorf("failed to create checkpoints: %w", err)
+	}
+	return nil
+}
+
+// Save records that the first loadJobs load jobs of a chunk committed rows rows, ending with lastRow.
+// The key values are stored as JSON of their converted values, so integer keys keep their type.
+func (c *checkpointer) Save(ctx context.Context, plan chunkPlan, loadJobs int, rows int64, lastRow map[string]any) error {
+	values := make([]any, len(c.keyColumns))
+	for i, col := range c.keyColumns {
+		values[i] = lastRow[col]
+	}
+	lastKey, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint key: %w", err)
+	}
+
+	return c.update(ctx, plan,
+		"last_primary_key = @last_primary_key, batches_committed = @batches_committed, rows_committed = @rows_committed",
+		bigquery.QueryParameter{Name: "last_primary_key", Value: string(lastKey)},
+		bigquery.QueryParameter{Name: "batches_committed", Value: int64(loadJobs)},
+		bigquery.QueryParameter{Name: "rows_committed", Value: rows},
+	)
+}
+
+// Complete records that every row of a chunk has been loaded.
+func (c *checkpointer) Complete(ctx context.Context, plan chunkPlan, rows int64) error {
+	return c.update(ctx, plan, "completed = TRUE, rows_committed = @rows_committed",
+		bigquery.QueryParameter{Name: "rows_committed", Value: rows},
+	)
+}
+
+// Clear deletes the checkpoints of the table once its sync has fully succeeded,
+// so that the next --resume run starts a new sync.
+func (c *checkpointer) Clear(ctx context.Context) error {
+	sql := fmt.Sprintf("DELETE FROM %s WHERE database_name = @database_name AND source_table = @source_table",
+		bigQueryTableRef(c.cfg, c.cfg.CheckpointTable))
+	if err := runBigQueryStatement(ctx, c.client, sql, c.keyParams()); err != nil {
+		return fmt.Errorf("failed to clear checkpoints: %w", err)
+	}
+	return nil
+}
+
+// update applies set to the checkpoint row of a chunk.
+func (c *checkpointer) update(ctx context.Context, plan chunkPlan, set string, params ...bigquery.QueryParameter) error {
+	sql := fmt.Sprintf(`UPDATE %s SET %s, updated_at = CURRENT_TIMESTAMP()
+WHERE database_name = @database_name AND source_table = @source_table AND run_id = @run_id AND chunk = @chunk`,
+		bigQueryTableRef(c.cfg, c.cfg.CheckpointTable), set)
+
+	params = append(params, c.keyParams()...)
+	params = append(params,
+		bigquery.QueryParameter{Name: "run_id", Value: c.runID},
+		bigquery.QueryParameter{Name: "chunk", Value: int64(plan.Index)},
+	)
+	if err := runBigQueryStatement(ctx, c.client, sql, params); err != nil {
+		return fmt.Errorf("failed to save checkpoint of chunk %d: %w", plan.Index, err)
+	}
+	return nil
+}
+
+func (c *checkpointer) keyParams() []bigquery.QueryParameter {
+	return stateKeyParams(c.dbName, c.tableName)
+}
+
+// resumeFromCheckpoints rebuilds the chunk plans of an interrupted sync from its checkpoints and
+// returns them with the run ID and the upper bound of the incremental window the sync used.
+func resumeFromCheckpoints(checkpoints []tableCheckpoint) ([]chunkPlan, string, *time.Time) {
+	plans := make([]chunkPlan, 0, len(checkpoints))
+	var windowTo *time.Time
+	for i := range checkpoints {
+		cp := &checkpoints[i]
+		plans = append(plans, chunkPlan{Index: int(cp.Chunk), Keys: cp.KeyRange(), Resume: cp})
+		if cp.WindowTo.Valid {
+			windowTo = &cp.WindowTo.Timestamp
+		}
+	}
+	return plans, checkpoints[0].RunID, windowTo
+}
+
+// keysetCondition returns the SQL condition and arguments selecting the rows that sort after the
+// given values of the ordering columns, which is how a resumed chunk skips the rows already loaded.
+// Placeholders are numbered from argOffset+1 so the condition can be combined with other filters.
+func keysetCondition(dbType string, columns []string, after []any, argOffset int) (string, []any) {
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = sqlPlaceholder(dbType, argOffset+i+1)
+	}
+	if len(columns) == 1 {
+		return fmt.Sprintf("%s > %s", columns[0], placeholders[0]), after
+	}
+	return fmt.Sprintf("(%s) > (%s)", strings.Join(columns, ", "), strings.Join(placeholders, ", ")), after
+}
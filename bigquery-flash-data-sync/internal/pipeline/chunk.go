@@ -0,0 +1,459 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// keyRange is a half-open range [Lower, Upper) of primary key values extracted by one chunk.
+// A missing bound is unbounded, so the first and last ranges also cover keys that fall
+// outside the MIN/MAX seen when the ranges were planned.
+type keyRange struct {
+	Lower    int64
+	Upper    int64
+	HasLower bool
+	HasUpper bool
+}
+
+func (r keyRange) String() string {
+	lower, upper := "-inf", "+inf"
+	if r.HasLower {
+		lower = strconv.FormatInt(r.Lower, 10)
+	}
+	if r.HasUpper {
+		upper = strconv.FormatInt(r.Upper, 10)
+	}
+	return "[" + lower + ", " + upper + ")"
+}
+
+// splitKeyRanges turns sorted, distinct boundaries into contiguous ranges covering the whole key space.
+func splitKeyRanges(boundaries []int64) []keyRange {
+	ranges := make([]keyRange, 0, len(boundaries)+1)
+	var prev keyRange
+	for _, b := range boundaries {
+		prev.Upper, prev.HasUpper = b, true
+		ranges = append(ranges, prev)
+		prev = keyRange{Lower: b, HasLower: true}
+	}
+	return append(ranges, prev)
+}
+
+// evenBoundaries returns up to n-1 boundaries splitting [minKey, maxKey] into n ranges of similar width.
+// Fewer boundaries are returned when the key space is narrower than n.
+func evenBoundaries(minKey, maxKey int64, n int) []int64 {
+	if n < 2 || maxKey <= minKey {
+		return nil
+	}
+	// Unsigned arithmetic keeps the span exact even when it exceeds the int64 range.
+	span := uint64(maxKey) - uint64(minKey)
+	step := span / uint64(n)
+	if step == 0 {
+		step = 1
+	}
+
+	var boundaries []int64
+	for i := uint64(1); i < uint64(n); i++ {
+		offset := step * i
+		if offset > span {
+			break
+		}
+		boundaries = append(boundaries, int64(uint64(minKey)+offset))
+	}
+	return boundaries
+}
+
+// resolveKeyRanges plans the primary key ranges a chunked table is extracted in, either from the
+// configured split points or by splitting the MIN/MAX of the primary key (within the incremental
+// window, if any) evenly. Returns nil when the table should be extracted with a single query.
+func resolveKeyRanges(ctx context.Context, source *sourceReader, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, params sourceParams, logger *zap.Logger) ([]keyRange, error) {
+	if !tableConfig.IsChunked() {
+		return nil, nil
+	}
+
+	keys := tableConfig.GetPrimaryKeyColumns()
+	if len(keys) != 1 {
+		return nil, fmt.Errorf("chunked extraction requires a single-column primary key, got %d columns", len(keys))
+	}
+	pk := keys[0]
+	if err := validateSQLIdentifier(pk); err != nil {
+		return nil, fmt.Errorf("invalid primary key column: %w", err)
+	}
+
+	pkType := bigquery.FieldType("")
+	for _, field := range schema {
+		if field.Name == pk {
+			pkType = field.Type
+		}
+	}
+	if pkType != bigquery.IntegerFieldType {
+		logger.Warn("Primary key is not a selected integer column, extracting table with a single query",
+			zap.String("primary_key", pk),
+			zap.String("primary_key_type", string(pkType)))
+		return nil, nil
+	}
+
+	var boundaries []int64
+	if len(tableConfig.SplitPoints) > 0 {
+		boundaries = slices.Clone(tableConfig.SplitPoints)
+		slices.Sort(boundaries)
+		boundaries = slices.Compact(boundaries)
+	} else {
+		from, conditions, args, err := sourceSelection(dbConfig, tableConfig, params, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		query := fmt.Sprintf("SELECT MIN(%s), MAX(%s) FROM %s", pk, pk, from)
+		if params.Window != nil {
+			condition, conditionArgs := watermarkCondition(dbConfig.Type, tableConfig.TimestampColumn, params.Window, len(args))
+			conditions = append(conditions, condition)
+			args = append(args, conditionArgs...)
+		}
+		if len(conditions) > 0 {
+			query += " WHERE " + strings.Join(conditions, " AND ")
+		}
+
+		var minKey, maxKey sql.NullInt64
+		if err := source.queryRow(ctx, query, args, &minKey, &maxKey); err != nil {
+			return nil, fmt.Errorf("failed to read primary key range of %s: %w", pk, err)
+		}
+		if !minKey.Valid || !maxKey.Valid {
+			return nil, nil
+		}
+		boundaries = evenBoundaries(minKey.Int64, maxKey.Int64, tableConfig.ParallelChunks)
+
+		logger.Debug("Read primary key range",
+			zap.String("primary_key", pk),
+			zap.Int64("min", minKey.Int64),
+			zap.Int64("max", maxKey.Int64))
+	}
+
+	if len(boundaries) == 0 {
+		return nil, nil
+	}
+
+	ranges := splitKeyRanges(boundaries)
+	logger.Info("Planned primary key ranges for parallel extraction",
+		zap.String("primary_key", pk),
+		zap.Int("chunks", len(ranges)),
+		zap.Int64s("boundaries", boundaries))
+	return ranges, nil
+}
+
+// keyRangeCondition returns the SQL condition and arguments restricting a source query to a key range.
+// Placeholders are numbered from argOffset+1 so the condition can be combined with other filters.
+func keyRangeCondition(dbType, column string, r keyRange, argOffset int) (string, []any) {
+	switch {
+	case r.HasLower && r.HasUpper:
+		return fmt.Sprintf("%s >= %s AND %s < %s",
+				column, sqlPlaceholder(dbType, argOffset+1),
+				column, sqlPlaceholder(dbType, argOffset+2)),
+			[]any{r.Lower, r.Upper}
+	case r.HasLower:
+		return fmt.Sprintf("%s >= %s", column, sqlPlaceholder(dbType, argOffset+1)), []any{r.Lower}
+	case r.HasUpper:
+		return fmt.Sprintf("%s < %s", column, sqlPlaceholder(dbType, argOffset+1)), []any{r.Upper}
+	default:
+		return "TRUE", nil
+	}
+}
+
+// chunkPlan is one unit of extraction of a table: a primary key range (nil for the whole table)
+// and, when an interrupted sync is resumed, the chunk's checkpoint.
+type chunkPlan struct {
+	Index  int
+	Keys   *keyRange
+	Resume *tableCheckpoint
+}
+
+// planChunks returns one plan per key range, or a single plan for the whole table without ranges.
+func planChunks(ranges []keyRange) []chunkPlan {
+	if len(ranges) == 0 {
+		return []chunkPlan{{}}
+	}
+	plans := make([]chunkPlan, len(ranges))
+	for i := range ranges {
+		plans[i] = chunkPlan{Index: i, Keys: &ranges[i]}
+	}
+	return plans
+}
+
+// extractChunks runs executeJob for every chunk plan in parallel through the shared connection pool,
+// at most parallelism at a time, each chunk streaming the rows of its buildQuery query into its own
+// load jobs on job.LoadTable. The first failing chunk cancels the others. A single plan without a
+// key range is executed as a single query. When checkpoints is non-nil, the progress of every chunk
+// is saved after each committed load job.
+// Returns the total number of rows loaded by all chunks.
+func extractChunks(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, job model.Job, plans []chunkPlan, parallelism int, buildQuery func(keys *keyRange, after []any) (string, []any, error), orderBy []string, source *sourceReader, checkpoints *checkpointer, retry *retrier, logger *zap.Logger) (int64, error) {
+	// Row parse failures are limited per table, not per chunk.
+	var parseFailures atomic.Int64
+
+	if len(plans) == 1 && plans[0].Keys == nil {
+		return extractChunk(ctx, bqClient, cfg, job, plans[0], buildQuery, orderBy, source, &parseFailures, checkpoints, retry, logger)
+	}
+
+	limit := parallelism
+	if limit < 2 {
+		limit = len(plans)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(limit)
+
+	var totalRows atomic.Int64
+	for _, plan := range plans {
+		chunkJob := job
+		if chunkJob.LoadJobIDPrefix != "" {
+			chunkJob.LoadJobIDPrefix = fmt.Sprintf("%s_c%d", job.LoadJobIDPrefix, plan.Index)
+		}
+		chunkLogger := logger.With(zap.Int("chunk", plan.Index), zap.Stringer("key_range", plan.Keys))
+
+		g.Go(func() error {
+			rows, err := extractChunk(gctx, bqClient, cfg, chunkJob, plan, buildQuery, orderBy, source, &parseFailures, checkpoints, retry, chunkLogger)
+			totalRows.Add(rows)
+			if err != nil {
+				return fmt.Errorf("chunk %d %s: %w", plan.Index, plan.Keys, err)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return totalRows.Load(), err
+	}
+
+	logger.Info("All chunks extracted", zap.Int("chunks", len(plans)), zap.Int64("rows", totalRows.Load()))
+	return totalRows.Load(), nil
+}
+
+// extractChunk extracts and loads the rows of one chunk plan. A chunk resumed from a checkpoint
+// continues after the last row its committed load jobs loaded, and a chunk the interrupted sync
+// completed is skipped. Failing to save a checkpoint only makes a later resume replay more rows,
+// which the deterministic load job IDs skip, so it is logged but does not fail the chunk.
+func extractChunk(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, job model.Job, plan chunkPlan, buildQuery func(keys *keyRange, after []any) (string, []any, error), orderBy []string, source *sourceReader, parseFailures *atomic.Int64, checkpoints *checkpointer, retry *retrier, logger *zap.Logger) (int64, error) {
+	var after []any
+	if cp := plan.Resume; cp != nil {
+		if cp.Completed {
+			logger.Info("Chunk was completed by the interrupted sync, skipping",
+				zap.Int64("rows_committed", cp.RowsCommitted))
+			return cp.RowsCommitted, nil
+		}
+		var err error
+		if after, err = cp.LastKey(); err != nil {
+			return 0, err
+		}
+		if after != nil {
+			job.CommittedLoadJobs = int(cp.BatchesCommitted)
+			job.CommittedRows = cp.RowsCommitted
+			logger.Info("Resuming chunk after last committed row",
+				zap.Int64("load_jobs_committed", cp.BatchesCommitted),
+				zap.Int64("rows_committed", cp.RowsCommitted),
+				zap.Any("last_primary_key", after))
+		}
+	}
+
+	query, args, err := buildQuery(plan.Keys, after)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build query for chunk %d: %w", plan.Index, err)
+	}
+	job.Query = query
+	job.QueryArgs = args
+
+	if checkpoints != nil {
+		job.Checkpoint = func(loadJobs int, rows int64, lastRow map[string]any) error {
+			err := retry.do(ctx, "save checkpoint", func(ctx context.Context) error {
+				return checkpoints.Save(ctx, plan, loadJobs, rows, lastRow)
+			})
+			if err != nil {
+				logger.Warn("Failed to save checkpoint", zap.Int("load_jobs", loadJobs), zap.Error(err))
+			}
+			return nil
+		}
+	}
+
+	replayQuery := func(after []any) (string, []any, error) {
+		return buildQuery(plan.Keys, after)
+	}
+	rows, err := extractWithRetry(ctx, bqClient, cfg, job, replayQuery, orderBy, source, parseFailures, retry, logger)
+	if err != nil {
+		return rows, err
+	}
+
+	if checkpoints != nil {
+		err := retry.do(ctx, "complete checkpoint", func(ctx context.Context) error {
+			return checkpoints.Complete(ctx, plan, rows)
+		})
+		if err != nil {
+			logger.Warn("Failed to mark chunk checkpoint as completed", zap.Error(err))
+		}
+	}
+	return rows, nil
+}
+
+// sealedSegment is a load job whose rows an attempt of extractWithRetry streamed completely.
+type sealedSegment struct {
+	jobID     string
+	rows      int64
+	lastRow   map[string]any
+	committed bool // The attempt saw the load job succeed
+}
+
+// extractWithRetry runs executeJob and retries it after a transient failure when the failed attempt
+// can be replayed without duplicating rows: no load job was submitted yet, the first load job of the
+// new attempt truncates the load table, or load jobs have deterministic IDs. A load job counts as
+// submitted once all its rows are streamed, because from then on it may commit even if waiting for
+// it fails. Rows are then extracted
+// in orderBy order, and a replay continues after the last row of the last load job that committed,
+// which is exact even if rows were inserted, deleted or resized in the source meanwhile.
+func extractWithRetry(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, job model.Job, query func(after []any) (string, []any, error), orderBy []string, source *sourceReader, parseFailures *atomic.Int64, retry *retrier, logger *zap.Logger) (int64, error) {
+	replayByKey := job.LoadJobIDPrefix != "" && len(orderBy) > 0
+	sealed := make(map[int]*sealedSegment)
+	submitted := false
+	job.SegmentSealed = func(seq int, jobID string, rows int64, lastRow map[string]any) {
+		submitted = true
+		if replayByKey {
+			sealed[seq] = &sealedSegment{jobID: jobID, rows: rows, lastRow: lastRow}
+		}
+	}
+	checkpoint := job.Checkpoint
+	if replayByKey {
+		job.Checkpoint = func(loadJobs int, rows int64, lastRow map[string]any) error {
+			if seg := sealed[loadJobs-1]; seg != nil {
+				seg.committed = true
+			}
+			if checkpoint != nil {
+				return checkpoint(loadJobs, rows, lastRow)
+			}
+			return nil
+		}
+	}
+
+	failures := &parseFailureCount{total: parseFailures}
+	var rows int64
+	attempts := 0
+	err := retry.doIf(ctx, "extract and load", func(ctx context.Context) error {
+		attempts++
+		failures.reset()
+		if attempts > 1 && replayByKey {
+			if err := skipCommittedSegments(ctx, bqClient, &job, sealed, checkpoint, query, orderBy, logger); err != nil {
+				return err
+			}
+		}
+		var err error
+		rows, err = executeJob(ctx, bqClient, cfg, job, source, failures, logger)
+		if err == nil {
+			failures.commit()
+		}
+		return err
+	}, func(error) bool {
+		return !submitted || job.TruncateFirstLoad || job.LoadJobIDPrefix != ""
+	})
+	return rows, err
+}
+
+// parseFailureCount counts the row parse failures of one extraction attempt. They are added to
+// total, which may be shared by the chunks of a table, only when the attempt succeeds, so rows a
+// retried attempt reads again are not counted twice.
+type parseFailureCount struct {
+	total   *atomic.Int64
+	attempt int64
+}
+
+// add counts a failure of the current attempt and returns the failures of the table so far.
+func (c *parseFailureCount) add() int64 {
+	c.attempt++
+	return c.total.Load() + c.attempt
+}
+
+// reset discards the failures of a failed attempt.
+func (c *parseFailureCount) reset() {
+	c.attempt = 0
+}
+
+// commit adds the failures of the succeeded attempt to the total.
+func (c *parseFailureCount) commit() {
+	c.total.Add(c.attempt)
+	c.attempt = 0
+}
+
+// skipCommittedSegments moves the start of a replayed extraction past the load jobs that earlier
+// attempts sealed and that committed, including jobs whose outcome the failed attempt did not see,
+// and rebuilds the query to continue after the last row of the last of them. The checkpoint is
+// saved for every committed load job the attempts had not seen succeed.
+func skipCommittedSegments(ctx context.Context, bqClient *bigquery.Client, job *model.Job, sealed map[int]*sealedSegment, checkpoint func(int, int64, map[string]any) error, query func(after []any) (string, []any, error), orderBy []string, logger *zap.Logger) error {
+	var lastRow map[string]any
+	for seq := job.CommittedLoadJobs; sealed[seq] != nil; seq++ {
+		seg := sealed[seq]
+		if !seg.committed {
+			bqJob, err := bqClient.JobFromIDLocation(ctx, seg.jobID, bqClient.Location)
+			if err != nil {
+				if isNotFoundError(err) {
+					break
+				}
+				return fmt.Errorf("failed to look up BigQuery load job %s: %w", seg.jobID, err)
+			}
+			status, err := bqJob.Wait(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to wait for BigQuery job %s: %w", seg.jobID, err)
+			}
+			if status.Err() != nil {
+				break
+			}
+			seg.committed = true
+			if checkpoint != nil {
+				if err := checkpoint(seq+1, job.CommittedRows+seg.rows, seg.lastRow); err != nil {
+					return err
+				}
+			}
+		}
+		job.CommittedLoadJobs = seq + 1
+		job.CommittedRows += seg.rows
+		lastRow = seg.lastRow
+	}
+	if lastRow == nil {
+		return nil
+	}
+
+	after := make([]any, len(orderBy))
+	for i, col := range orderBy {
+		after[i] = lastRow[col]
+	}
+	q, args, err := query(after)
+	if err != nil {
+		return fmt.Errorf("failed to build replay query: %w", err)
+	}
+	job.Query = q
+	job.QueryArgs = args
+
+	logger.Info("Replaying extraction after last committed load job",
+		zap.Int("load_jobs_committed", job.CommittedLoadJobs),
+		zap.Int64("rows_committed", job.CommittedRows),
+		zap.Any("last_primary_key", after))
+	return nil
+}
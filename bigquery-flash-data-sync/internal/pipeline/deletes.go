@@ -0,0 +1,162 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// reconcileDeletes finds the rows of the target table whose primary key no longer exists in the
+// source and deletes or flags them according to tableConfig.DeleteDetection. Every source key is
+// extracted into a per-run staging table, which is then compared with the target in one MERGE.
+func reconcileDeletes(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, runStartedAt time.Time, source *sourceReader, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, targetTable string, retry *retrier, logger *zap.Logger) error {
+	keys := tableConfig.GetPrimaryKeyColumns()
+	if selectedColumns(schema, keys) == nil {
+		return fmt.Errorf("primary key columns %v are not all synced", keys)
+	}
+
+	keySchema := make(bigquery.Schema, 0, len(keys))
+	for _, key := range keys {
+		for _, field := range schema {
+			if field.Name == key {
+				keySchema = append(keySchema, field)
+			}
+		}
+	}
+
+	// The key extract reads the whole table, whatever the incremental window of the sync.
+	keyConfig := *tableConfig
+	keyConfig.Columns = keys
+	query, args, err := buildSourceQuery(dbConfig, &keyConfig, sourceParams{RunStartedAt: runStartedAt}, nil, keys, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build key query: %w", err)
+	}
+
+	keysTable := stagingTableName(targetTable, "keys", runID)
+	err = retry.do(ctx, "create key staging table", func(ctx context.Context) error {
+		return createStagingTable(ctx, bqClient, cfg.BigQueryDatasetID, keysTable, keySchema, logger)
+	})
+	if err != nil {
+		return err
+	}
+	defer dropStagingTable(context.WithoutCancel(ctx), bqClient, cfg.BigQueryDatasetID, keysTable, logger)
+
+	// A key that cannot be converted would look deleted as well.
+	converter := model.NewRowConverter(keySchema, func(string) string { return model.InvalidValueFail }, cfg.DateFormat, logger)
+
+	job := model.Job{
+		Name:              tableConfig.Name,
+		DatabaseName:      dbConfig.Name,
+		DatabaseType:      dbConfig.Type,
+		ConnectionString:  dbConfig.ConnectionString,
+		SourceTable:       tableConfig.Name,
+		TargetTable:       targetTable,
+		LoadTable:         keysTable,
+		RunID:             runID,
+		Query:             query,
+		QueryArgs:         args,
+		Columns:           keys,
+		PrimaryKey:        tableConfig.PrimaryKey,
+		MaxLoadBytes:      cfg.LoadJobMaxBytes,
+		TruncateFirstLoad: true,
+		LoadJobIDPrefix:   loadJobIDPrefix(runID, dbConfig.Name, keysTable),
+		ParseFunc: func(rows *sql.Rows, logger *zap.Logger) (model.Savable, error) {
+			return model.ParseDynamicRow(rows, converter)
+		},
+	}
+
+	// A key that fails to parse would look deleted, so the key extract tolerates no parse failures.
+	keyCfg := *cfg
+	keyCfg.MaxRowParseFailures = 0
+	var parseFailures atomic.Int64
+	replayQuery := func(after []any) (string, []any, error) {
+		return buildSourceQuery(dbConfig, &keyConfig, sourceParams{RunStartedAt: runStartedAt}, nil, keys, after)
+	}
+	sourceKeys, err := extractWithRetry(ctx, bqClient, &keyCfg, job, replayQuery, keys, source, &parseFailures, retry, logger)
+	if err != nil {
+		return fmt.Errorf("failed to extract source keys: %w", err)
+	}
+
+	stmt, err := buildDeleteReconciliation(cfg, keysTable, targetTable, keys, tableConfig.DeleteDetection, tableConfig.KeepsHistory())
+	if err != nil {
+		return err
+	}
+	logger.Debug("Reconciling deleted rows",
+		zap.String("key_table", keysTable),
+		zap.String("statement", stmt))
+
+	err = retry.do(ctx, "reconcile deleted rows", func(ctx context.Context) error {
+		return runBigQueryStatement(ctx, bqClient, stmt, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile deleted rows of '%s': %w", targetTable, err)
+	}
+
+	logger.Info("Reconciled rows deleted in the source",
+		zap.String("delete_detection", tableConfig.DeleteDetection),
+		zap.Int64("source_keys", sourceKeys))
+	return nil
+}
+
+// buildDeleteReconciliation builds the MERGE statement that deletes (mode delete) or flags (mode flag)
+// the target rows whose key is not in the key table. In flag mode, flagged rows whose key exists in
+// the source again are unflagged. For a history table (history), either mode closes the current
+// version of such rows instead; a key that reappears gets a new version from the next apply.
+func buildDeleteReconciliation(cfg *model.Config, keysTable, target string, keys []string, mode string, history bool) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("delete detection requires at least one primary key column")
+	}
+
+	quotedKeys := make([]string, 0, len(keys))
+	joins := make([]string, 0, len(keys))
+	for _, key := range keys {
+		quotedKeys = append(quotedKeys, quoteBigQueryIdentifier(key))
+		joins = append(joins, fmt.Sprintf("T.%s = S.%s", quoteBigQueryIdentifier(key), quoteBigQueryIdentifier(key)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE %s T\n", bigQueryTableRef(cfg, target))
+	fmt.Fprintf(&b, "USING (SELECT DISTINCT %s FROM %s) S\n", strings.Join(quotedKeys, ", "), bigQueryTableRef(cfg, keysTable))
+	fmt.Fprintf(&b, "ON %s\n", strings.Join(joins, " AND "))
+
+	switch {
+	case history:
+		isCurrent := quoteBigQueryIdentifier(isCurrentColumn)
+		fmt.Fprintf(&b, "WHEN NOT MATCHED BY SOURCE AND T.%s THEN\n  UPDATE SET %s = CURRENT_TIMESTAMP(), %s = FALSE",
+			isCurrent, quoteBigQueryIdentifier(validToColumn), isCurrent)
+	case mode == model.DeleteDetectionDelete:
+		b.WriteString("WHEN NOT MATCHED BY SOURCE THEN\n  DELETE")
+	case mode == model.DeleteDetectionFlag:
+		isDeleted := "T." + quoteBigQueryIdentifier(isDeletedColumn)
+		fmt.Fprintf(&b, "WHEN MATCHED AND %s THEN\n  UPDATE SET %s = FALSE, %s = NULL\n",
+			isDeleted, quoteBigQueryIdentifier(isDeletedColumn), quoteBigQueryIdentifier(deletedAtColumn))
+		fmt.Fprintf(&b, "WHEN NOT MATCHED BY SOURCE AND %s IS NOT TRUE THEN\n  UPDATE SET %s = TRUE, %s = CURRENT_TIMESTAMP()",
+			isDeleted, quoteBigQueryIdentifier(isDeletedColumn), quoteBigQueryIdentifier(deletedAtColumn))
+	default:
+		return "", fmt.Errorf("unsupported delete detection mode %q", mode)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// discoverTables replaces the tables of a database configured with {DB}_TABLES=* by the base tables
+// found in its source catalog that pass the include and exclude patterns. Each discovered table
+// gets the default table configuration, overridden by any {DB}_{TABLE}_* settings.
+func discoverTables(ctx context.Context, pool *sourcePool, cfg *model.Config, logger *zap.Logger) error {
+	dbConfig := pool.dbConfig
+	if dbConfig.DiscoveredTableConfig == nil {
+		return fmt.Errorf("table discovery is not configured for database '%s'", dbConfig.Name)
+	}
+
+	db, err := pool.open(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	names, err := listSourceTables(ctx, db, dbConfig)
+	if err != nil {
+		return fmt.Errorf("failed to list source tables: %w", err)
+	}
+
+	tables := make(map[string]*model.TableConfig, len(names))
+	excluded := 0
+	for _, name := range names {
+		if !dbConfig.IncludesTable(name) {
+			excluded++
+			continue
+		}
+		if err := validateSQLIdentifier(name); err != nil {
+			logger.Warn("Skipping discovered table with an unsupported name", zap.String("table", name))
+			excluded++
+			continue
+		}
+		tableConfig, err := dbConfig.DiscoveredTableConfig(name)
+		if err != nil {
+			return fmt.Errorf("invalid config for discovered table '%s': %w", name, err)
+		}
+		tables[name] = tableConfig
+	}
+	dbConfig.Tables = tables
+
+	logger.Info("Discovered source tables",
+		zap.Int("tables", len(tables)),
+		zap.Int("excluded", excluded),
+	)
+	return nil
+}
+
+// listSourceTables returns the names of the base tables of the database (MySQL) or schema
+// (PostgreSQL) named by DatabaseName. Views are left out, and so are the partitions of a
+// PostgreSQL partitioned table, which is read through its parent.
+func listSourceTables(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig) ([]string, error) {
+	var query string
+	switch strings.ToLower(dbConfig.Type) {
+	case "mysql":
+		query = `SELECT TABLE_NAME FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = ? AND TABLE_TYPE = 'BASE TABLE'
+			ORDER BY TABLE_NAME`
+	case "postgres":
+		query = `SELECT c.relname FROM pg_catalog.pg_class c
+			JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = $1 AND c.relkind IN ('r', 'p') AND NOT c.relispartition
+			ORDER BY c.relname`
+	default:
+		return nil, fmt.Errorf("table discovery is not supported for database type '%s'", dbConfig.Type)
+	}
+
+	rows, err := db.QueryContext(ctx, query, dbConfig.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
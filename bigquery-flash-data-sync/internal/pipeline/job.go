@@ -20,10 +20,11 @@
 package pipeline
 
 import (
-    "bytes"
     "context"
+    "crypto/rand"
     "database/sql"
-    "encoding/json"
+    "encoding/hex"
+    "errors"
     "fmt"
     "regexp"
     "strings"
@@ -34,6 +35,7 @@ import (
     "github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
     "go.uber.org/zap"
     "golang.org/x/sync/errgroup"
+    "golang.org/x/sync/semaphore"
 )
 
 // DBDriver defines the function signature for getting the SQL driver name.
@@ -56,6 +58,8 @@ func getDBDriver(dbType string) string {
 }
 
 // Start initializes the BigQuery client and orchestrates multiple concurrent ETL jobs.
+// At most cfg.MaxConcurrentTables tables sync at once, and at most MaxConcurrentTables of each
+// database; all tables of a database share one connection pool.
 // NOTE: We intentionally do NOT cancel all jobs on first failure, to avoid "context canceled"
 // hiding the real errors from other tables.
 func Start(ctx context.Context, cfg *model.Config, logger *zap.Logger) error {
@@ -70,20 +74,64 @@ func Start(ctx context.Context, cfg *model.Config, logger *zap.Logger) error {
     }
     defer bqClient.Close()
 
-    enabledDatabases := cfg.GetEnabledDatabases()
-    totalTables := cfg.CountEnabledTables()
+    // Load jobs are looked up by their deterministic IDs, which requires the dataset's location.
+    if md, err := bqClient.Dataset(cfg.BigQueryDatasetID).Metadata(ctx); err != nil {
+        logger.Warn("Failed to read BigQuery dataset location, using the default job location", zap.Error(err))
+    } else {
+        bqClient.Location = md.Location
+    }
 
+    enabledDatabases := cfg.GetEnabledDatabases()
     if len(enabledDatabases) == 0 {
         logger.Warn("No enabled databases found in configuration")
         return nil
     }
 
+    pools := make(map[*model.DatabaseConfig]*sourcePool, len(enabledDatabases))
+    for _, db := range enabledDatabases {
+        pool := newSourcePool(db, db.MaxConcurrentTables)
+        defer pool.Close()
+        pools[db] = pool
+    }
+
+    // Tables of databases configured with TABLES=* are discovered before anything is counted.
+    // A database whose tables cannot be listed is reported as a failed sync; the others still run.
+    discoveryErrors := make(map[*model.DatabaseConfig]error)
+    for _, db := range enabledDatabases {
+        if !db.DiscoverTables {
+            continue
+        }
+        if err := discoverTables(ctx, pools[db], cfg, logger.With(zap.String("database", db.Name))); err != nil {
+            logger.Error("Table discovery failed", zap.String("database", db.Name), zap.Error(err))
+            discoveryErrors[db] = fmt.Errorf("table discovery failed: %w", err)
+        }
+    }
+    totalTables := cfg.CountEnabledTables()
+
     logger.Info("Starting sync pipeline",
         zap.Int("enabled_databases", len(enabledDatabases)),
         zap.Int("total_tables", totalTables),
         zap.Bool("dry_run", cfg.DryRun),
+        zap.Bool("resume", cfg.Resume),
+        zap.Int("max_concurrent_tables", cfg.MaxConcurrentTables),
     )
 
+    if !cfg.DryRun && hasIncrementalTables(enabledDatabases) {
+        if err := ensureSyncStateTable(ctx, bqClient, cfg, logger); err != nil {
+            return fmt.Errorf("failed to prepare sync state table: %w", err)
+        }
+    }
+
+    if !cfg.DryRun && cfg.Checkpoints {
+        if err := ensureCheckpointTable(ctx, bqClient, cfg, logger); err != nil {
+            return fmt.Errorf("failed to prepare checkpoint table: %w", err)
+        }
+    }
+
+    runStartedAt := time.Now()
+    runID := newRunID(runStartedAt)
+    logger = logger.With(zap.String("run_id", runID))
+
     summary := &model.SyncSummary{
         TotalDatabases: len(enabledDatabases),
         TotalTables:    totalTables,
@@ -92,12 +140,32 @@ func Start(ctx context.Context, cfg *model.Config, logger *zap.Logger) error {
 
     // Use mutex to protect concurrent access to summary counters
     var mu sync.Mutex
-    resultsChan := make(chan *model.SyncResult, totalTables)
+    resultsChan := make(chan *model.SyncResult, totalTables+len(discoveryErrors))
 
     var g errgroup.Group
+    tableSlots := newLimiter(cfg.MaxConcurrentTables)
 
     for _, dbConfig := range enabledDatabases {
         db := dbConfig
+        pool := pools[db]
+
+        if discoveryErr, failed := discoveryErrors[db]; failed {
+            now := time.Now()
+            resultsChan <- &model.SyncResult{
+                DatabaseName: db.Name,
+                TableName:    "*",
+                Error:        discoveryErr,
+                StartedAt:    now,
+                CompletedAt:  now,
+            }
+            mu.Lock()
+            summary.FailedSyncs++
+            mu.Unlock()
+            g.Go(func() error {
+                return fmt.Errorf("failed sync at %s: %w", db.Name, discoveryErr)
+            })
+            continue
+        }
 
         for _, tableConfig := range db.GetEnabledTables() {
             tbl := tableConfig
@@ -118,7 +186,7 @@ func Start(ctx context.Context, cfg *model.Config, logger *zap.Logger) error {
             g.Go(func() error {
                 // Use the original ctx (no group-cancel context) so one failing table
                 // doesn't cancel all other in-flight table jobs.
-                result := runTableJob(ctx, bqClient, cfg, db, tbl, jobLogger)
+                result := runTableJob(ctx, bqClient, cfg, runID, runStartedAt, pool, tableSlots, tbl, jobLogger)
 
                 resultsChan <- result
 
@@ -165,9 +233,41 @@ func Start(ctx context.Context, cfg *model.Config, logger *zap.Logger) error {
     return nil
 }
 
+// newRunID returns an identifier for a pipeline run that is safe to embed in BigQuery table and job names.
+func newRunID(startedAt time.Time) string {
+    suffix := make([]byte, 3)
+    _, _ = rand.Read(suffix)
+    return startedAt.UTC().Format("20060102t150405") + "_" + hex.EncodeToString(suffix)
+}
+
+// runIDStartedAt returns when the run with the given ID started, to the second, or false if the ID
+// was not created by newRunID.
+func runIDStartedAt(runID string) (time.Time, bool) {
+    stamp, _, _ := strings.Cut(runID, "_")
+    startedAt, err := time.Parse("20060102t150405", stamp)
+    return startedAt, err == nil
+}
+
+// hasIncrementalTables reports whether any enabled table is synced incrementally or from a change log,
+// both of which keep state in the sync state table.
+func hasIncrementalTables(databases []*model.DatabaseConfig) bool {
+    for _, db := range databases {
+        for _, tbl := range db.GetEnabledTables() {
+            if tbl.IsIncremental() || tbl.IsCDC() {
+                return true
+            }
+        }
+    }
+    return false
+}
+
 // runTableJob handles the ETL process for a single table, including schema inference,
-// BigQuery table creation/update, data extraction, and load.
-func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, logger *zap.Logger) *model.SyncResult {
+// BigQuery table creation/update, data extraction, and load. It waits for a table slot of the
+// database's pool and of tableSlots before starting and uses the pool's shared connections.
+// Progress is checkpointed after every committed load job, and with cfg.Resume an interrupted
+// sync of the table is continued from its checkpoints instead of starting over.
+func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, runStartedAt time.Time, pool *sourcePool, tableSlots *semaphore.Weighted, tableConfig *model.TableConfig, logger *zap.Logger) *model.SyncResult {
+    dbConfig := pool.dbConfig
     startedAt := time.Now()
 
     rawTarget := tableConfig.GetTargetTableName()
@@ -191,13 +291,16 @@ func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Conf
         StartedAt:    startedAt,
     }
 
+    retry := newRetrier(cfg, logger)
+
     finishErr := func(publicMsg string, err error) *model.SyncResult {
         if err == nil {
-            err = fmt.Errorf(publicMsg)
+            err = errors.New(publicMsg)
         } else if publicMsg != "" {
             err = fmt.Errorf("%s: %w", publicMsg, err)
         }
         result.Error = err
+        result.Retries = retry.Retries()
         result.CompletedAt = time.Now()
         result.Duration = result.CompletedAt.Sub(result.StartedAt)
 
@@ -210,14 +313,25 @@ func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Conf
     }
 
     finishOK := func() *model.SyncResult {
+        result.Retries = retry.Retries()
         result.CompletedAt = time.Now()
         result.Duration = result.CompletedAt.Sub(result.StartedAt)
         return result
     }
 
+    logger.Debug("Waiting for a table slot")
+    releaseSlot, err := pool.acquireTableSlot(ctx, tableSlots)
+    if err != nil {
+        return finishErr("Cancelled while waiting for a table slot", err)
+    }
+    defer releaseSlot()
+
+    // Time spent queued behind other tables does not count towards the table's duration.
+    result.StartedAt = time.Now()
+
     logger.Info("Starting table sync job")
 
-    sourceQuery, err := buildSourceQuery(dbConfig, tableConfig)
+    sourceQuery, sourceArgs, err := buildSourceQuery(dbConfig, tableConfig, sourceParams{RunStartedAt: runStartedAt}, nil, nil, nil)
     if err != nil {
         return finishErr("Failed to build source query", err)
     }
@@ -227,13 +341,41 @@ func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Conf
         zap.String("source_query", sourceQuery),
     )
 
-    db, err := openDatabaseConnection(ctx, dbConfig, cfg, logger)
+    var db *sql.DB
+    err = retry.do(ctx, "open database connection", func(ctx context.Context) error {
+        db, err = pool.open(ctx, cfg, logger)
+        return err
+    })
     if err != nil {
         return finishErr("Database connection failed", err)
     }
-    defer db.Close()
 
-    inferredSchema, err := InferSchemaFromDatabase(db, dbConfig.Type, dbConfig.Name, dummyQuery, logger)
+    // The copy of a CDC table is read at the current state instead: its changes are applied from
+    // a change log position read after the snapshot was taken.
+    source := &sourceReader{db: db}
+    if dbConfig.ConsistentSnapshot && !cfg.DryRun && !tableConfig.IsCDC() {
+        err = retry.do(ctx, "begin consistent snapshot", func(ctx context.Context) error {
+            source.snapshot, err = pool.consistentSnapshot(ctx, db, logger)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to begin consistent snapshot", err)
+        }
+    }
+
+    var inferredSchema bigquery.Schema
+    err = retry.do(ctx, "schema inference", func(ctx context.Context) error {
+        // A custom QUERY, or a table the catalog does not describe, is inferred from the column
+        // types of the LIMIT 1 query instead.
+        if tableConfig.Query == "" {
+            inferredSchema, err = InferSchemaFromCatalog(ctx, db, dbConfig, tableConfig, logger)
+            if err != nil || inferredSchema != nil {
+                return err
+            }
+        }
+        inferredSchema, err = InferSchemaFromDatabase(db, dbConfig.Type, dbConfig.Name, dummyQuery, logger, sourceArgs...)
+        return err
+    })
     if err != nil {
         return finishErr("Schema inference failed", err)
     }
@@ -242,74 +384,493 @@ func runTableJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Conf
         zap.Int("columns", len(inferredSchema)),
     )
 
+    // Extracting in primary key order makes the load job segments reproducible, so a replayed
+    // extraction can skip the segments an earlier attempt already loaded (see loadStream), and
+    // lets a checkpoint record the last row loaded.
+    orderBy := selectedColumns(inferredSchema, tableConfig.GetPrimaryKeyColumns())
+
+    // A CDC table is copied in full by its first sync, which records the change log position the
+    // copy started at; every later sync applies the changes logged since the stored position.
+    var cdcPosition string
+    if tableConfig.IsCDC() {
+        err = retry.do(ctx, "load change log position", func(ctx context.Context) error {
+            cdcPosition, _, err = loadCDCPosition(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to load change log position", err)
+        }
+    }
+    applyingChanges := cdcPosition != ""
+
+    // The initial copy of a CDC table is not checkpointed: a resumed copy would need the change
+    // log position of the interrupted one.
+    checkpointing := cfg.Checkpoints && len(orderBy) > 0 && !cfg.DryRun && !tableConfig.IsCDC()
+
+    // A resumed sync keeps the run ID (and so the staging table and load job IDs), the window
+    // upper bound and the chunks of the interrupted sync.
+    syncRunID := runID
+    var plans []chunkPlan
+    var resumeWindowTo *time.Time
+    if cfg.Resume && checkpointing {
+        var saved []tableCheckpoint
+        err = retry.do(ctx, "load checkpoints", func(ctx context.Context) error {
+            saved, err = loadCheckpoints(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to load checkpoints", err)
+        }
+        if len(saved) > 0 {
+            plans, syncRunID, resumeWindowTo = resumeFromCheckpoints(saved)
+            logger = logger.With(zap.String("resumed_run_id", syncRunID))
+            logger.Info("Resuming interrupted table sync from checkpoint", zap.Int("chunks", len(plans)))
+        } else {
+            logger.Info("No checkpoint found, starting a new table sync")
+        }
+    }
+    resuming := plans != nil
+
+    var window *watermarkWindow
+    if tableConfig.IsIncremental() {
+        err = retry.do(ctx, "resolve watermark window", func(ctx context.Context) error {
+            window, err = resolveWatermarkWindow(ctx, bqClient, cfg, source, dbConfig, tableConfig, runStartedAt, logger)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to resolve incremental watermark", err)
+        }
+        if window == nil {
+            logger.Info("Timestamp column has no values, nothing to sync incrementally")
+            return finishOK()
+        }
+        if resumeWindowTo != nil {
+            window.To = *resumeWindowTo
+            logger.Info("Using watermark window of the interrupted sync", zap.Time("window_to", window.To))
+        }
+    }
+
+    if !resuming && !applyingChanges {
+        var ranges []keyRange
+        err = retry.do(ctx, "plan primary key ranges", func(ctx context.Context) error {
+            ranges, err = resolveKeyRanges(ctx, source, dbConfig, tableConfig, inferredSchema, sourceParams{Window: window, RunStartedAt: runStartedAt}, logger)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to plan primary key ranges", err)
+        }
+        plans = planChunks(ranges)
+    }
+    chunked := len(plans) > 0 && plans[0].Keys != nil
+
+    buildQuery := func(keys *keyRange, after []any) (string, []any, error) {
+        return buildSourceQuery(dbConfig, tableConfig, sourceParams{Window: window, RunStartedAt: runStartedAt}, keys, orderBy, after)
+    }
+
+    sourceQuery, queryArgs, err := buildQuery(nil, nil)
+    if err != nil {
+        return finishErr("Failed to build source query", err)
+    }
+    logger.Debug("Generated extraction query", zap.String("source_query", sourceQuery))
+
     if cfg.DryRun {
         logger.Info("Dry run mode - skipping BigQuery operations")
         return finishOK()
     }
 
-    bqTable := model.BQTable{Name: targetTableName, Schema: inferredSchema}
+    bqTable := model.BQTable{
+        Name:             targetTableName,
+        Schema:           targetSchema(inferredSchema, tableConfig),
+        TimePartitioning: targetPartitioning(tableConfig),
+    }
 
     if cfg.CreateTables {
-        if err := createOrUpdateTable(ctx, bqClient, cfg.BigQueryDatasetID, bqTable, logger); err != nil {
+        var loadTarget string
+        err := retry.do(ctx, "create or update table", func(ctx context.Context) error {
+            var err error
+            loadTarget, err = createOrUpdateTable(ctx, bqClient, cfg.BigQueryDatasetID, bqTable, tableConfig.SchemaPolicy, logger)
+            return err
+        })
+        if err != nil {
             return finishErr("BigQuery table creation failed", err)
         }
+        if loadTarget != targetTableName {
+            // Incompatible schema change quarantined: everything below writes to the versioned table.
+            result.QuarantineTable = loadTarget
+            targetTableName = loadTarget
+            logger = logger.With(zap.String("quarantine_table", loadTarget))
+        }
+    }
+
+    if applyingChanges {
+        changes, err := syncChanges(ctx, bqClient, cfg, runID, db, dbConfig, tableConfig, inferredSchema, targetTableName, cdcPosition, retry, logger)
+        result.RowsSynced = changes
+        if err != nil {
+            return finishErr("Applying change log failed", err)
+        }
+        finishOK()
+
+        logger.Info("Table change log sync completed successfully",
+            zap.Int64("changes_applied", changes),
+            zap.Int64("retries", result.Retries),
+            zap.Duration("duration", result.Duration),
+        )
+        return result
+    }
+
+    // Every row of a snapshot carries the same run metadata.
+    snapshotTakenAt := time.Now()
+
+    // Merges, full refreshes, snapshots and chunked extractions load into a per-run staging table first, which is
+    // then applied to the target in a single statement or copy job so a failed load never leaves it
+    // half written.
+    loadTable := targetTableName
+    stagingKind := stagingKindFor(tableConfig.WriteMode)
+    if stagingKind == "" && chunked {
+        stagingKind = "staging"
+    }
+    staged := stagingKind != ""
+    var checkpoints *checkpointer
+    if staged {
+        loadTable = stagingTableName(targetTableName, stagingKind, syncRunID)
+        if resuming {
+            var found bool
+            err := retry.do(ctx, "look up staging table", func(ctx context.Context) error {
+                _, err := bqClient.Dataset(cfg.BigQueryDatasetID).Table(loadTable).Metadata(ctx)
+                if isNotFoundError(err) {
+                    return nil
+                }
+                found = err == nil
+                return err
+            })
+            if err != nil {
+                return finishErr("Failed to look up staging table of the interrupted sync", err)
+            }
+            if !found {
+                // The rows loaded so far are gone (e.g. the staging table expired): extract the same chunks
+                // again under this run's ID, so that no load job of the interrupted sync is mistaken as done.
+                logger.Warn("Staging table of the interrupted sync no longer exists, extracting the table again",
+                    zap.String("staging_table", loadTable))
+                for i := range plans {
+                    plans[i].Resume = nil
+                }
+                resuming = false
+                syncRunID = runID
+                loadTable = stagingTableName(targetTableName, stagingKind, syncRunID)
+            }
+        }
+
+        err := retry.do(ctx, "create staging table", func(ctx context.Context) error {
+            // Staged rows are copied into the target as they are, so the staging table carries its system columns too.
+            return createStagingTable(ctx, bqClient, cfg.BigQueryDatasetID, loadTable, bqTable.Schema, logger)
+        })
+        if err != nil {
+            return finishErr("BigQuery staging table creation failed", err)
+        }
+        defer func() {
+            // Keep the rows a failed sync staged so that --resume can continue; the table expires on its own.
+            if result.Error != nil && checkpoints != nil {
+                logger.Info("Keeping staging table of the failed sync for --resume",
+                    zap.String("staging_table", loadTable),
+                    zap.Duration("ttl", stagingTableTTL))
+                return
+            }
+            dropStagingTable(context.WithoutCancel(ctx), bqClient, cfg.BigQueryDatasetID, loadTable, logger)
+        }()
     }
 
+    if checkpointing {
+        checkpoints = newCheckpointer(bqClient, cfg, dbConfig.Name, tableConfig.Name, syncRunID, orderBy)
+        if !resuming {
+            err := retry.do(ctx, "create checkpoints", func(ctx context.Context) error {
+                return checkpoints.Begin(ctx, plans, window)
+            })
+            if err != nil {
+                // Without checkpoint rows the sync still runs, it just cannot be resumed.
+                logger.Warn("Failed to create checkpoints, the sync cannot be resumed if interrupted", zap.Error(err))
+                checkpoints = nil
+            }
+        }
+    }
+
+    converter := model.NewRowConverter(inferredSchema, tableConfig.InvalidValuePolicy, cfg.DateFormat, logger)
+
     job := model.Job{
-        Name:             tableConfig.Name,
-        DatabaseName:     dbConfig.Name,
-        DatabaseType:     dbConfig.Type,
-        ConnectionString: dbConfig.ConnectionString,
-        SourceTable:      tableConfig.Name,
-        TargetTable:      targetTableName,
-        Query:            sourceQuery,
-        Columns:          tableConfig.Columns,
-        PrimaryKey:       tableConfig.PrimaryKey,
-        TimestampColumn:  tableConfig.TimestampColumn,
-        BatchSize:        tableConfig.GetBatchSize(cfg.DefaultBatchSize),
+        Name:              tableConfig.Name,
+        DatabaseName:      dbConfig.Name,
+        DatabaseType:      dbConfig.Type,
+        ConnectionString:  dbConfig.ConnectionString,
+        SourceTable:       tableConfig.Name,
+        TargetTable:       targetTableName,
+        LoadTable:         loadTable,
+        RunID:             syncRunID,
+        Query:             sourceQuery,
+        QueryArgs:         queryArgs,
+        Columns:           tableConfig.Columns,
+        PrimaryKey:        tableConfig.PrimaryKey,
+        TimestampColumn:   tableConfig.TimestampColumn,
+        BatchSize:         tableConfig.GetBatchSize(cfg.DefaultBatchSize),
+        MaxLoadBytes:      cfg.LoadJobMaxBytes,
+        // Chunks load concurrently into the fresh per-run staging table, so none of them may truncate it.
+        TruncateFirstLoad: staged && !chunked,
         ParseFunc: func(rows *sql.Rows, logger *zap.Logger) (model.Savable, error) {
-            return model.ParseDynamicRow(rows, logger, cfg.DateFormat)
+            row, err := model.ParseDynamicRow(rows, converter)
+            if err != nil {
+                return nil, err
+            }
+            if tableConfig.HashesRows() {
+                row = withRowHash(row)
+            }
+            if tableConfig.TakesSnapshots() {
+                row = withSnapshotColumns(row, syncRunID, snapshotTakenAt, dbConfig.Name)
+            }
+            return row, nil
         },
     }
 
-    rowsSynced, err := executeJob(ctx, bqClient, cfg, job, db, logger)
+    if len(orderBy) > 0 {
+        job.LoadJobIDPrefix = loadJobIDPrefix(syncRunID, dbConfig.Name, loadTable)
+    }
+
+    // Changes committed while the table is copied are applied again by the next sync, which the
+    // merge of change records makes harmless.
+    var changeLogStart string
+    if tableConfig.IsCDC() {
+        err := retry.do(ctx, "read change log position", func(ctx context.Context) error {
+            var err error
+            changeLogStart, err = changeLogStartPosition(ctx, db, dbConfig, tableConfig, logger)
+            return err
+        })
+        if err != nil {
+            return finishErr("Failed to read change log position", err)
+        }
+        logger.Info("Copying table before applying its change log", zap.String("change_log_position", changeLogStart))
+    }
+
+    rowsSynced, err := extractChunks(ctx, bqClient, cfg, job, plans, tableConfig.ParallelChunks, buildQuery, orderBy, source, checkpoints, retry, logger)
     if err != nil {
         return finishErr("Job execution failed", err)
     }
 
+    switch tableConfig.WriteMode {
+    case model.WriteModeAppend:
+        if staged && rowsSynced > 0 {
+            err := retry.do(ctx, "append staging table", func(ctx context.Context) error {
+                return appendStagingToTarget(ctx, bqClient, cfg, loadTable, targetTableName, bqTable.Schema, loadJobIDPrefix(syncRunID, dbConfig.Name, loadTable), logger)
+            })
+            if err != nil {
+                return finishErr("Appending staged rows to target failed", err)
+            }
+            logger.Info("Appended staged rows to target table")
+        }
+    case model.WriteModeMerge:
+        if rowsSynced > 0 {
+            keys := tableConfig.GetPrimaryKeyColumns()
+            orderBy := mergeOrderBy(inferredSchema, tableConfig.TimestampColumn)
+            err := retry.do(ctx, "merge staging table", func(ctx context.Context) error {
+                return mergeStagingIntoTarget(ctx, bqClient, cfg, loadTable, targetTableName, rowSchema(inferredSchema, tableConfig), keys, orderBy, logger)
+            })
+            if err != nil {
+                return finishErr("Merge into target table failed", err)
+            }
+            logger.Info("Merged staged rows into target table", zap.Strings("primary_key", keys))
+        }
+    case model.WriteModeSCD2:
+        if rowsSynced > 0 {
+            keys := tableConfig.GetPrimaryKeyColumns()
+            orderBy := mergeOrderBy(inferredSchema, tableConfig.TimestampColumn)
+            err := retry.do(ctx, "apply staging table to history", func(ctx context.Context) error {
+                return applyHistoryFromStaging(ctx, bqClient, cfg, loadTable, targetTableName, rowSchema(inferredSchema, tableConfig), keys, orderBy, logger)
+            })
+            if err != nil {
+                return finishErr("Applying history to target table failed", err)
+            }
+            logger.Info("Applied staged rows to history table", zap.Strings("primary_key", keys))
+        }
+    case model.WriteModeSnapshot:
+        if rowsSynced > 0 {
+            // A resumed run keeps the rows the interrupted one staged, which were taken after it started.
+            snapshotSince := snapshotTakenAt
+            if startedAt, ok := runIDStartedAt(syncRunID); ok && startedAt.Before(snapshotSince) {
+                snapshotSince = startedAt
+            }
+            err := retry.do(ctx, "append snapshot table", func(ctx context.Context) error {
+                return appendSnapshotFromStaging(ctx, bqClient, cfg, loadTable, targetTableName, bqTable.Schema, syncRunID, dbConfig.Name, snapshotSince, logger)
+            })
+            if err != nil {
+                return finishErr("Appending snapshot to target table failed", err)
+            }
+            logger.Info("Appended snapshot to target table", zap.Time("synced_at", snapshotTakenAt))
+        }
+    case model.WriteModeTruncate:
+        // An empty shadow table is swapped in as well: the source table is empty.
+        err := retry.do(ctx, "replace target table", func(ctx context.Context) error {
+            return replaceTargetFromStaging(ctx, bqClient, cfg.BigQueryDatasetID, loadTable, targetTableName, logger)
+        })
+        if err != nil {
+            return finishErr("Swapping shadow table into target failed", err)
+        }
+        logger.Info("Replaced target table with fully loaded shadow table")
+    }
+
+    if tableConfig.DeleteDetection != model.DeleteDetectionNone {
+        err := reconcileDeletes(ctx, bqClient, cfg, runID, runStartedAt, source, dbConfig, tableConfig, inferredSchema, targetTableName, retry, logger)
+        if err != nil {
+            return finishErr("Delete reconciliation failed", err)
+        }
+    }
+
+    // Only advance the watermark once every load job for the window has succeeded. Rows loaded into a
+    // quarantine table have not reached the target, so the watermark stays where it is until the
+    // incompatible schema change is resolved and the window is loaded into the target.
+    if window != nil && result.QuarantineTable != "" {
+        logger.Warn("Incremental watermark not advanced while loading into quarantine table",
+            zap.Time("window_to", window.To))
+    } else if window != nil {
+        next := window.NextWatermark()
+        err := retry.do(ctx, "save watermark", func(ctx context.Context) error {
+            return saveWatermark(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name, next)
+        })
+        if err != nil {
+            return finishErr("Failed to save incremental watermark", err)
+        }
+        logger.Info("Incremental watermark advanced", zap.Time("watermark", next))
+    }
+
+    if changeLogStart != "" {
+        err := retry.do(ctx, "save change log position", func(ctx context.Context) error {
+            return saveCDCPosition(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name, changeLogStart)
+        })
+        if err != nil {
+            return finishErr("Failed to save change log position", err)
+        }
+        logger.Info("Change log position recorded, later syncs apply changes from it")
+    }
+
+    if checkpoints != nil {
+        err := retry.do(ctx, "clear checkpoints", func(ctx context.Context) error {
+            return checkpoints.Clear(ctx)
+        })
+        if err != nil {
+            logger.Warn("Failed to clear checkpoints of the completed sync", zap.Error(err))
+        }
+    }
+
     result.RowsSynced = rowsSynced
     finishOK()
 
     logger.Info("Table sync job completed successfully",
         zap.Int64("rows_synced", rowsSynced),
+        zap.Int64("retries", result.Retries),
         zap.Duration("duration", result.Duration),
     )
 
     return result
 }
 
-// buildSourceQuery constructs the SQL query for extracting data from the source table.
-//
-// Design note:
-//   - For MySQL, DatabaseName represents the database and queries are generated as: database.table
-//   - For PostgreSQL, DatabaseName represents the schema and queries are generated as: schema.table
-//
-// This implementation also supports fully-qualified table names passed via config (e.g., "schema.table").
-//
-// PostgreSQL connections are always made to a single database via the connection string.
-// Schema selection is handled explicitly at the query level.
-func buildSourceQuery(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig) (string, error) {
+// buildSourceQuery constructs the SQL query for extracting data from the source table, or from the
+// table's custom QUERY, restricted by its WHERE (see sourceSelection).
+// When params.Window is non-nil the query is restricted to the incremental watermark window, and when
+// keys is non-nil to a primary key range; the returned arguments must be bound to the placeholders.
+// Rows are returned in orderBy order when it is non-empty, starting after the orderBy values after
+// when those are given.
+func buildSourceQuery(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, params sourceParams, keys *keyRange, orderBy []string, after []any) (string, []any, error) {
     // Columns: validate as single-part identifiers.
     columns := "*"
     if len(tableConfig.Columns) > 0 {
         for _, col := range tableConfig.Columns {
             if err := validateSQLIdentifier(col); err != nil {
-                return "", fmt.Errorf("invalid column name: %w", err)
+                return "", nil, fmt.Errorf("invalid column name: %w", err)
             }
         }
         columns = strings.Join(tableConfig.Columns, ", ")
     }
 
+    from, conditions, args, err := sourceSelection(dbConfig, tableConfig, params, 0)
+    if err != nil {
+        return "", nil, err
+    }
+
+    query := fmt.Sprintf("SELECT %s FROM %s", columns, from)
+
+    if params.Window != nil {
+        if err := validateSQLIdentifier(tableConfig.TimestampColumn); err != nil {
+            return "", nil, fmt.Errorf("invalid timestamp column: %w", err)
+        }
+        condition, conditionArgs := watermarkCondition(dbConfig.Type, tableConfig.TimestampColumn, params.Window, len(args))
+        conditions = append(conditions, condition)
+        args = append(args, conditionArgs...)
+    }
+    if keys != nil {
+        pk := tableConfig.GetPrimaryKeyColumns()
+        if len(pk) != 1 {
+            return "", nil, fmt.Errorf("key ranges require a single-column primary key")
+        }
+        if err := validateSQLIdentifier(pk[0]); err != nil {
+            return "", nil, fmt.Errorf("invalid primary key column: %w", err)
+        }
+        condition, conditionArgs := keyRangeCondition(dbConfig.Type, pk[0], *keys, len(args))
+        conditions = append(conditions, condition)
+        args = append(args, conditionArgs...)
+    }
+    if len(after) > 0 {
+        if len(after) != len(orderBy) {
+            return "", nil, fmt.Errorf("resume key has %d values but rows are ordered by %d columns", len(after), len(orderBy))
+        }
+        for _, col := range orderBy {
+            if err := validateSQLIdentifier(col); err != nil {
+                return "", nil, fmt.Errorf("invalid order by column: %w", err)
+            }
+        }
+        condition, conditionArgs := keysetCondition(dbConfig.Type, orderBy, after, len(args))
+        conditions = append(conditions, condition)
+        args = append(args, conditionArgs...)
+    }
+
+    if len(conditions) > 0 {
+        query += " WHERE " + strings.Join(conditions, " AND ")
+    }
+
+    if len(orderBy) > 0 {
+        for _, col := range orderBy {
+            if err := validateSQLIdentifier(col); err != nil {
+                return "", nil, fmt.Errorf("invalid order by column: %w", err)
+            }
+        }
+        query += " ORDER BY " + strings.Join(orderBy, ", ")
+    }
+
+    return query, args, nil
+}
+
+// selectedColumns returns columns if every one of them is a field of the inferred schema, or nil otherwise.
+func selectedColumns(schema bigquery.Schema, columns []string) []string {
+    if len(columns) == 0 {
+        return nil
+    }
+    fields := make(map[string]bool, len(schema))
+    for _, field := range schema {
+        fields[field.Name] = true
+    }
+    for _, col := range columns {
+        if !fields[col] {
+            return nil
+        }
+    }
+    return columns
+}
+
+// sourceTableRef returns the qualified reference of the source table for use in SQL queries.
+//
+// Design note:
+//   - For MySQL, DatabaseName represents the database and queries are generated as: database.table
+//   - For PostgreSQL, DatabaseName represents the schema and queries are generated as: schema.table
+//
+// This implementation also supports fully-qualified table names passed via config (e.g., "schema.table").
+//
+// PostgreSQL connections are always made to a single database via the connection string.
+// Schema selection is handled explicitly at the query level.
+func sourceTableRef(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig) (string, error) {
     dbType := strings.ToLower(dbConfig.Type)
 
     // Tables may come as "table" or "schema.table" (or "database.table" for MySQL).
@@ -332,12 +893,7 @@ func buildSourceQuery(dbConfig *model.DatabaseConfig, tableConfig *model.TableCo
             schema = dbConfig.DatabaseName
         }
 
-        return fmt.Sprintf(
-            "SELECT %s FROM %s.%s",
-            columns,
-            schema,
-            table,
-        ), nil
+        return schema + "." + table, nil
 
     default:
         // MySQL and others: Prefer database provided in the table name (db.table). Fallback to DatabaseName.
@@ -349,15 +905,18 @@ func buildSourceQuery(dbConfig *model.DatabaseConfig, tableConfig *model.TableCo
             dbName = dbConfig.DatabaseName
         }
 
-        return fmt.Sprintf(
-            "SELECT %s FROM %s.%s",
-            columns,
-            dbName,
-            table,
-        ), nil
+        return dbName + "." + table, nil
     }
 }
 
+// sqlPlaceholder returns the bind placeholder for the n-th (1-based) query argument.
+func sqlPlaceholder(dbType string, n int) string {
+    if strings.ToLower(dbType) == "postgres" {
+        return fmt.Sprintf("$%d", n)
+    }
+    return "?"
+}
+
 var validSQLIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 
 // validateSQLIdentifier ensures the identifier is safe to insert into a SQL query.
@@ -472,31 +1031,31 @@ func openDatabaseConnection(ctx context.Context, dbConfig *model.DatabaseConfig,
     return db, nil
 }
 
-// executeJob runs a full extract-and-load process by querying the source database, buffering results in memory,
-// and uploading the extracted JSON data to BigQuery using a load job.
+// executeJob runs a full extract-and-load process by querying the source database and streaming
+// the rows as NDJSON into BigQuery load jobs while they are read, so memory use does not grow with
+// the size of the table. A load job is completed and a new one started whenever the job's row or
+// byte limit is reached.
+// Row parse failures are counted in parseFailures as failures of the current extraction attempt.
 // Returns the number of rows synced and an error if any stage fails.
-func executeJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, job model.Job, db *sql.DB, logger *zap.Logger) (int64, error) {
-    if db == nil {
+func executeJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, job model.Job, source *sourceReader, parseFailures *parseFailureCount, logger *zap.Logger) (int64, error) {
+    if source == nil || source.db == nil {
         return 0, fmt.Errorf("database connection is nil")
     }
 
     logger.Info("Executing source query", zap.String("job_name", job.Name))
 
-    rows, err := db.QueryContext(ctx, job.Query)
+    rows, done, err := source.query(ctx, job.Query, job.QueryArgs...)
     if err != nil {
         logger.Error("Failed to query database", zap.Error(err))
         return 0, fmt.Errorf("failed to query database: %w", err)
     }
+    defer done()
     defer rows.Close()
 
-    maxRowsPerBatch := job.BatchSize
     maxRowParseFailures := cfg.MaxRowParseFailures
 
-    var buf bytes.Buffer
-    encoder := json.NewEncoder(&buf)
+    stream := newLoadStream(ctx, bqClient, cfg.BigQueryDatasetID, job, logger)
 
-    var batch []model.Savable
-    var totalRowsExtracted int64
     var skippedRows int
     var lastParseError error
     rowNum := 0
@@ -504,67 +1063,62 @@ func executeJob(ctx context.Context, bqClient *bigquery.Client, cfg *model.Confi
     for rows.Next() {
         rowNum++
         rowData, err := job.ParseFunc(rows, logger)
+        var invalidErr *model.InvalidValueError
+        if errors.As(err, &invalidErr) && invalidErr.Policy == model.InvalidValueFail {
+            err = fmt.Errorf("row %d: %w", rowNum, err)
+            stream.Abort(err)
+            return stream.RowsLoaded(), err
+        }
+        if err == nil {
+            err = stream.Write(rowData.ToSaveable())
+            var encodeErr *rowEncodeError
+            if err != nil && !errors.As(err, &encodeErr) {
+                stream.Abort(err)
+                return stream.RowsLoaded(), err
+            }
+        }
         if err != nil {
             logger.Error("Failed to parse row", zap.Int("row_number", rowNum), zap.Error(err))
             skippedRows++
             lastParseError = err
+            totalFailures := parseFailures.add()
 
             // A negative value (-1) means unlimited failures are allowed
-            if maxRowParseFailures >= 0 && skippedRows > maxRowParseFailures {
+            if maxRowParseFailures >= 0 && totalFailures > int64(maxRowParseFailures) {
                 logger.Error("Exceeded maximum row parse failures, aborting sync",
                     zap.Int("max_failures_allowed", maxRowParseFailures),
-                    zap.Int("total_failures", skippedRows),
+                    zap.Int64("total_failures", totalFailures),
                     zap.Int("rows_processed", rowNum),
-                    zap.Int64("rows_successfully_extracted", totalRowsExtracted),
+                    zap.Int64("rows_successfully_loaded", stream.RowsLoaded()),
                     zap.Error(lastParseError),
                 )
-                return totalRowsExtracted, fmt.Errorf("exceeded maximum row parse failures (%d/%d), last error: %w",
-                    skippedRows, maxRowParseFailures, lastParseError)
+                err = fmt.Errorf("exceeded maximum row parse failures (%d/%d), last error: %w",
+                    totalFailures, maxRowParseFailures, lastParseError)
+                stream.Abort(err)
+                return stream.RowsLoaded(), err
             }
             continue
         }
-
-        batch = append(batch, rowData)
-
-        if len(batch) >= maxRowsPerBatch {
-            for _, r := range batch {
-                if err := encoder.Encode(r.ToSaveable()); err != nil {
-                    return 0, fmt.Errorf("failed to encode batch: %w", err)
-                }
-            }
-
-            if err := uploadBufferToBigQuery(ctx, bqClient, cfg, job.TargetTable, &buf, cfg.TruncateOnSync && totalRowsExtracted == 0); err != nil {
-                return 0, err
-            }
-
-            totalRowsExtracted += int64(len(batch))
-            buf.Reset()
-            batch = batch[:0]
-        }
-    }
-
-    // Upload any remaining rows
-    if len(batch) > 0 {
-        for _, r := range batch {
-            if err := encoder.Encode(r.ToSaveable()); err != nil {
-                return 0, fmt.Errorf("failed to encode batch: %w", err)
-            }
-        }
-        if err := uploadBufferToBigQuery(ctx, bqClient, cfg, job.TargetTable, &buf, cfg.TruncateOnSync && totalRowsExtracted == 0); err != nil {
-            return 0, err
-        }
-        totalRowsExtracted += int64(len(batch))
     }
 
     if err := rows.Err(); err != nil {
         logger.Error("Error during row iteration", zap.Error(err))
-        return 0, fmt.Errorf("error during row iteration: %w", err)
+        err = fmt.Errorf("error during row iteration: %w", err)
+        stream.Abort(err)
+        return stream.RowsLoaded(), err
     }
 
+    // Complete the last load job
+    if err := stream.Close(); err != nil {
+        return stream.RowsLoaded(), err
+    }
+    totalRowsExtracted := stream.RowsLoaded()
+
     logger.Info("Extraction complete",
         zap.Int("total_rows_processed", rowNum),
         zap.Int64("rows_extracted", totalRowsExtracted),
         zap.Int("rows_skipped", skippedRows),
+        zap.Int("load_jobs", stream.Jobs()),
     )
 
     if skippedRows > 0 {
@@ -599,14 +1153,24 @@ func logSyncSummary(logger *zap.Logger, summary *model.SyncSummary) {
                 zap.String("database", result.DatabaseName),
                 zap.String("table", result.TableName),
                 zap.Error(result.Error),
+                zap.Int64("retries", result.Retries),
                 zap.Duration("duration", result.Duration),
             )
+        } else if result.QuarantineTable != "" {
+            logger.Warn("Sync succeeded into quarantine table after an incompatible schema change",
+                zap.String("database", result.DatabaseName),
+                zap.String("table", result.TableName),
+                zap.String("target", result.TargetTable),
+                zap.String("quarantine_table", result.QuarantineTable),
+                zap.Int64("rows", result.RowsSynced),
+            )
         } else {
             logger.Debug("Sync succeeded",
                 zap.String("database", result.DatabaseName),
                 zap.String("table", result.TableName),
                 zap.String("target", result.TargetTable),
                 zap.Int64("rows", result.RowsSynced),
+                zap.Int64("retries", result.Retries),
                 zap.Duration("duration", result.Duration),
             )
         }
@@ -630,37 +1194,3 @@ func formatBigQueryStatusErrors(status *bigquery.JobStatus) string {
     
     return b.String()
 }
-
-// uploadBufferToBigQuery uploads the JSON data stored in an in-memory buffer to a BigQuery table.
-// It creates a BigQuery load job using the provided buffer as the source. The `truncate` flag
-// controls whether the target table is overwritten (WriteTruncate) or appended to (WriteAppend).
-// After the upload completes successfully, the buffer is reset for reuse.
-// Returns an error if the load job creation, execution, or completion fails.
-func uploadBufferToBigQuery(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, table string, buf *bytes.Buffer, truncate bool) error {
-    source := bigquery.NewReaderSource(buf)
-    source.SourceFormat = bigquery.JSON
-
-    loader := bqClient.Dataset(cfg.BigQueryDatasetID).Table(table).LoaderFrom(source)
-    if truncate {
-        loader.WriteDisposition = bigquery.WriteTruncate
-    } else {
-        loader.WriteDisposition = bigquery.WriteAppend
-    }
-
-    bqJob, err := loader.Run(ctx)
-    if err != nil {
-        return fmt.Errorf("failed to create BigQuery load job: %w", err)
-    }
-
-    status, err := bqJob.Wait(ctx)
-    if err != nil {
-        return fmt.Errorf("failed to wait for BigQuery job: %w", err)
-    }
-
-    if stErr := status.Err(); stErr != nil {
-        return fmt.Errorf("BigQuery load job failed: %w.%s", stErr, formatBigQueryStatusErrors(status))
-    }
-
-    buf.Reset()
-    return nil
-}
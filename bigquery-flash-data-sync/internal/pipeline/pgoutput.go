@@ -0,0 +1,488 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/jackc/pglogrepl"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgproto3"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// walStatusInterval is how often the replication connection reports its confirmed position and
+// asks the server for a keepalive, which tells how far the server has decoded the WAL.
+const walStatusInterval = time.Second
+
+// validateReplicationName ensures a replication slot or publication name is safe to use unquoted.
+// PostgreSQL folds unquoted names to lower case, so only lower-case names are accepted.
+func validateReplicationName(name string) error {
+	if err := validateSQLIdentifier(name); err != nil {
+		return err
+	}
+	if strings.ToLower(name) != name || len(name) > 63 {
+		return fmt.Errorf("invalid replication name %q: use at most 63 lower-case letters, digits and underscores", name)
+	}
+	return nil
+}
+
+// currentWALPosition returns the current end of the source's write-ahead log.
+func currentWALPosition(ctx context.Context, db *sql.DB) (pglogrepl.LSN, error) {
+	var position string
+	if err := db.QueryRowContext(ctx, "SELECT pg_current_wal_lsn()::text").Scan(&position); err != nil {
+		return 0, fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	return pglogrepl.ParseLSN(position)
+}
+
+// connectReplication opens a logical replication connection with the database's connection string.
+func connectReplication(ctx context.Context, dbConfig *model.DatabaseConfig) (*pgconn.PgConn, error) {
+	// Replication commands wait for WAL, so the statement timeout of regular queries does not apply.
+	conn, err := pgconn.Connect(ctx, dbConfig.ConnectionString+" replication=database options='-c statement_timeout=0'")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replication connection: %w", err)
+	}
+	return conn, nil
+}
+
+// ensureReplicationSlot creates the publication and the logical replication slot of a table if they
+// do not exist yet and returns the position from which the slot retains changes, as an LSN string.
+func ensureReplicationSlot(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, logger *zap.Logger) (string, error) {
+	if err := validateReplicationName(tableConfig.CDCPublication); err != nil {
+		return "", fmt.Errorf("invalid publication name: %w", err)
+	}
+	if err := validateReplicationName(tableConfig.CDCSlot); err != nil {
+		return "", fmt.Errorf("invalid replication slot name: %w", err)
+	}
+
+	var publications int
+	err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM pg_publication WHERE pubname = $1", tableConfig.CDCPublication).Scan(&publications)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up publication '%s': %w", tableConfig.CDCPublication, err)
+	}
+	if publications == 0 {
+		tableRef, err := sourceTableRef(dbConfig, tableConfig)
+		if err != nil {
+			return "", err
+		}
+		// Truncates cannot be expressed as change records, so they are not published.
+		stmt := fmt.Sprintf("CREATE PUBLICATION %s FOR TABLE %s WITH (publish = 'insert, update, delete')", tableConfig.CDCPublication, tableRef)
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return "", fmt.Errorf("failed to create publication '%s': %w", tableConfig.CDCPublication, err)
+		}
+		logger.Info("Created publication", zap.String("publication", tableConfig.CDCPublication))
+	}
+
+	// A slot left by an earlier copy that failed still retains every change since it was created.
+	var confirmed sql.NullString
+	err = db.QueryRowContext(ctx, "SELECT confirmed_flush_lsn::text FROM pg_replication_slots WHERE slot_name = $1", tableConfig.CDCSlot).Scan(&confirmed)
+	switch {
+	case err == nil:
+		if !confirmed.Valid {
+			return "", fmt.Errorf("replication slot '%s' exists but is not a logical replication slot", tableConfig.CDCSlot)
+		}
+		logger.Info("Using existing replication slot", zap.String("slot", tableConfig.CDCSlot))
+		return confirmed.String, nil
+	case !errors.Is(err, sql.ErrNoRows):
+		return "", fmt.Errorf("failed to look up replication slot '%s': %w", tableConfig.CDCSlot, err)
+	}
+
+	conn, err := connectReplication(ctx, dbConfig)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	slot, err := pglogrepl.CreateReplicationSlot(ctx, conn, tableConfig.CDCSlot, "pgoutput", pglogrepl.CreateReplicationSlotOptions{
+		Mode:           pglogrepl.LogicalReplication,
+		SnapshotAction: "NOEXPORT_SNAPSHOT",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create replication slot '%s': %w", tableConfig.CDCSlot, err)
+	}
+	logger.Info("Created replication slot",
+		zap.String("slot", tableConfig.CDCSlot),
+		zap.String("consistent_point", slot.ConsistentPoint))
+	return slot.ConsistentPoint, nil
+}
+
+// syncWALChanges applies the changes of a table decoded by its replication slot since the stored
+// position from, up to the end of the WAL at the time of the call. Changes are applied in
+// micro-batches of tableConfig.CDCBatchSize; after each, the position is saved and confirmed to
+// the slot so the server can recycle the WAL before it. Returns the number of change records applied.
+func syncWALChanges(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, runID string, db *sql.DB, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, schema bigquery.Schema, targetTable, from string, retry *retrier, logger *zap.Logger) (int64, error) {
+	start, err := pglogrepl.ParseLSN(from)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL position %q: %w", from, err)
+	}
+	if err := validateReplicationName(tableConfig.CDCPublication); err != nil {
+		return 0, fmt.Errorf("invalid publication name: %w", err)
+	}
+	if err := validateReplicationName(tableConfig.CDCSlot); err != nil {
+		return 0, fmt.Errorf("invalid replication slot name: %w", err)
+	}
+
+	var end pglogrepl.LSN
+	err = retry.do(ctx, "read WAL position", func(ctx context.Context) error {
+		end, err = currentWALPosition(ctx, db)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if start >= end {
+		logger.Info("No new WAL since the stored position", zap.String("position", from))
+		return 0, nil
+	}
+
+	sourceSchema, sourceTable, err := sourceSchemaAndTable(dbConfig, tableConfig)
+	if err != nil {
+		return 0, err
+	}
+
+	sink, dropStaging, err := newChangeSink(ctx, bqClient, cfg, runID, dbConfig, tableConfig, schema, targetTable, retry, logger)
+	if err != nil {
+		return 0, err
+	}
+	defer dropStaging()
+
+	conn, err := connectReplication(ctx, dbConfig)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close(context.WithoutCancel(ctx))
+
+	err = pglogrepl.StartReplication(ctx, conn, tableConfig.CDCSlot, start, pglogrepl.StartReplicationOptions{
+		Mode: pglogrepl.LogicalReplication,
+		PluginArgs: []string{
+			"proto_version '1'",
+			fmt.Sprintf("publication_names '%s'", tableConfig.CDCPublication),
+		},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to start replication from slot '%s': %w", tableConfig.CDCSlot, err)
+	}
+
+	logger.Info("Reading WAL changes",
+		zap.String("slot", tableConfig.CDCSlot),
+		zap.String("from", start.String()),
+		zap.String("to", end.String()))
+
+	fields := make(map[string]*bigquery.FieldSchema, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = field
+	}
+
+	r := &walReader{
+		conn:      conn,
+		schema:    sourceSchema,
+		table:     sourceTable,
+		fields:    fields,
+		relations: make(map[uint32]*pglogrepl.RelationMessage),
+		pos:       start,
+		confirmed: start,
+		end:       end,
+		sink:      sink,
+		logger:    logger,
+	}
+	r.savePosition = func(ctx context.Context) error {
+		return saveCDCPosition(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name, r.pos.String())
+	}
+
+	if err := r.run(ctx); err != nil {
+		sink.applier.Abort(err)
+		return sink.applier.Applied(), err
+	}
+	return sink.applier.Applied(), nil
+}
+
+// walReader turns the pgoutput messages of one table into change records. pgoutput sends every
+// transaction once it has committed; its changes are staged at the commit message.
+type walReader struct {
+	conn      *pgconn.PgConn
+	schema    string
+	table     string
+	fields    map[string]*bigquery.FieldSchema // Columns synced to BigQuery
+	relations map[uint32]*pglogrepl.RelationMessage
+	pos       pglogrepl.LSN // End of the last transaction read
+	confirmed pglogrepl.LSN // Position saved after the last applied micro-batch
+	end       pglogrepl.LSN
+	sink      *changeSink
+	logger    *zap.Logger
+
+	savePosition func(ctx context.Context) error
+
+	inTxn      bool
+	commitTime time.Time
+}
+
+// run reads messages until every transaction committed before the end position has been read,
+// then applies the last micro-batch.
+func (r *walReader) run(ctx context.Context) error {
+	nextStatus := time.Now()
+	for r.pos < r.end {
+		if !time.Now().Before(nextStatus) {
+			if err := r.sendStatus(ctx); err != nil {
+				return err
+			}
+			nextStatus = time.Now().Add(walStatusInterval)
+		}
+
+		receiveCtx, cancel := context.WithDeadline(ctx, nextStatus)
+		msg, err := r.conn.ReceiveMessage(receiveCtx)
+		cancel()
+		if err != nil {
+			if pgconn.Timeout(err) && ctx.Err() == nil {
+				continue
+			}
+			return fmt.Errorf("failed to read replication stream: %w", err)
+		}
+
+		switch msg := msg.(type) {
+		case *pgproto3.ErrorResponse:
+			return fmt.Errorf("replication stream failed: %w", pgconn.ErrorResponseToPgError(msg))
+		case *pgproto3.CopyData:
+			if err := r.handleCopyData(msg.Data); err != nil {
+				return err
+			}
+		}
+
+		if !r.inTxn && r.sink.applier.Full() {
+			if err := r.flush(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return r.flush(ctx)
+}
+
+// flush applies the current micro-batch, saves the position and confirms it to the slot.
+func (r *walReader) flush(ctx context.Context) error {
+	if err := r.sink.applier.Flush(r.savePosition); err != nil {
+		return err
+	}
+	r.confirmed = r.pos
+	return r.sendStatus(ctx)
+}
+
+// sendStatus reports the confirmed position to the server and asks for a keepalive.
+func (r *walReader) sendStatus(ctx context.Context) error {
+	err := pglogrepl.SendStandbyStatusUpdate(ctx, r.conn, pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: r.confirmed,
+		ReplyRequested:   true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send replication status: %w", err)
+	}
+	return nil
+}
+
+// handleCopyData processes one message of the replication stream.
+func (r *walReader) handleCopyData(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	switch data[0] {
+	case pglogrepl.PrimaryKeepaliveMessageByteID:
+		keepalive, err := pglogrepl.ParsePrimaryKeepaliveMessage(data[1:])
+		if err != nil {
+			return fmt.Errorf("invalid keepalive message: %w", err)
+		}
+		// The server has sent every transaction that committed before the position it has decoded up to.
+		if !r.inTxn && keepalive.ServerWALEnd > r.pos {
+			r.pos = keepalive.ServerWALEnd
+		}
+	case pglogrepl.XLogDataByteID:
+		xld, err := pglogrepl.ParseXLogData(data[1:])
+		if err != nil {
+			return fmt.Errorf("invalid WAL data message: %w", err)
+		}
+		msg, err := pglogrepl.Parse(xld.WALData)
+		if err != nil {
+			return fmt.Errorf("invalid pgoutput message: %w", err)
+		}
+		return r.handle(msg)
+	}
+	return nil
+}
+
+// handle processes one pgoutput message.
+func (r *walReader) handle(msg pglogrepl.Message) error {
+	switch m := msg.(type) {
+	case *pglogrepl.RelationMessage:
+		r.relations[m.RelationID] = m
+	case *pglogrepl.BeginMessage:
+		r.inTxn = true
+		r.commitTime = m.CommitTime
+		r.sink.begin()
+	case *pglogrepl.CommitMessage:
+		if err := r.sink.commit(r.commitTime); err != nil {
+			return err
+		}
+		r.pos = m.TransactionEndLSN
+		r.inTxn = false
+	case *pglogrepl.InsertMessage:
+		rel := r.relation(m.RelationID)
+		if rel == nil {
+			return nil
+		}
+		row, err := r.rowFromTuple(rel, m.Tuple, nil)
+		if err != nil {
+			return err
+		}
+		r.sink.insert(row)
+	case *pglogrepl.UpdateMessage:
+		rel := r.relation(m.RelationID)
+		if rel == nil {
+			return nil
+		}
+		after, err := r.rowFromTuple(rel, m.NewTuple, m.OldTuple)
+		if err != nil {
+			return err
+		}
+		// The old key is only sent when it changed (or with REPLICA IDENTITY FULL).
+		var before map[string]any
+		if m.OldTuple != nil {
+			before, err = r.rowFromTuple(rel, m.OldTuple, nil)
+			if err != nil {
+				return err
+			}
+		}
+		r.sink.update(before, after)
+	case *pglogrepl.DeleteMessage:
+		rel := r.relation(m.RelationID)
+		if rel == nil {
+			return nil
+		}
+		row, err := r.rowFromTuple(rel, m.OldTuple, nil)
+		if err != nil {
+			return err
+		}
+		r.sink.delete(row)
+	}
+	return nil
+}
+
+// relation returns the relation of a row message if it is the synced table, or nil otherwise.
+func (r *walReader) relation(id uint32) *pglogrepl.RelationMessage {
+	rel, ok := r.relations[id]
+	if !ok || !strings.EqualFold(rel.Namespace, r.schema) || !strings.EqualFold(rel.RelationName, r.table) {
+		return nil
+	}
+	return rel
+}
+
+// rowFromTuple converts a tuple of a row message into a row of the synced columns. Unchanged
+// TOASTed values are not sent in updates; they are taken from old, the old row image, if given.
+func (r *walReader) rowFromTuple(rel *pglogrepl.RelationMessage, tuple, old *pglogrepl.TupleData) (map[string]any, error) {
+	if tuple == nil {
+		return nil, fmt.Errorf("row message of '%s.%s' carries no row", r.schema, r.table)
+	}
+	if len(tuple.Columns) != len(rel.Columns) {
+		return nil, fmt.Errorf("row message of '%s.%s' has %d columns, expected %d", r.schema, r.table, len(tuple.Columns), len(rel.Columns))
+	}
+
+	row := make(map[string]any, len(r.fields))
+	for i, column := range rel.Columns {
+		field, ok := r.fields[column.Name]
+		if !ok {
+			continue
+		}
+
+		col := tuple.Columns[i]
+		if col.DataType == pglogrepl.TupleDataTypeToast {
+			if old == nil || i >= len(old.Columns) || old.Columns[i].DataType != pglogrepl.TupleDataTypeText {
+				return nil, fmt.Errorf("update of '%s.%s' does not carry the unchanged value of column %q; set REPLICA IDENTITY FULL on the table",
+					r.schema, r.table, column.Name)
+			}
+			col = old.Columns[i]
+		}
+
+		switch col.DataType {
+		case pglogrepl.TupleDataTypeNull:
+			row[column.Name] = nil
+		case pglogrepl.TupleDataTypeText:
+			// Arrays are parsed from their text by the converter. Text the BigQuery type cannot be
+			// parsed from, e.g. an infinite date, is left to the converter's invalid value policy.
+			var val any = string(col.Data)
+			if !field.Repeated {
+				parsed, err := pgTextValue(string(col.Data), field.Type)
+				if err != nil && field.Type == bigquery.BytesFieldType {
+					return nil, fmt.Errorf("column %q: %w", column.Name, err)
+				}
+				if err == nil {
+					val = parsed
+				}
+			}
+			converted, err := r.sink.convert(column.Name, val)
+			if err != nil {
+				return nil, err
+			}
+			row[column.Name] = converted
+		default:
+			return nil, fmt.Errorf("column %q: unexpected pgoutput value format %q", column.Name, col.DataType)
+		}
+	}
+	return row, nil
+}
+
+// pgTimestampLayouts are the text formats of PostgreSQL timestamps, with and without time zone.
+var pgTimestampLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+}
+
+// pgTextValue converts a value in PostgreSQL text format into the Go type the snapshot path
+// reads for a column of the BigQuery type.
+func pgTextValue(text string, fieldType bigquery.FieldType) (any, error) {
+	switch fieldType {
+	case bigquery.IntegerFieldType:
+		return strconv.ParseInt(text, 10, 64)
+	case bigquery.FloatFieldType:
+		return strconv.ParseFloat(text, 64)
+	case bigquery.BooleanFieldType:
+		return text == "t", nil
+	case bigquery.DateFieldType:
+		return time.Parse(time.DateOnly, text)
+	case bigquery.TimestampFieldType:
+		for _, layout := range pgTimestampLayouts {
+			if t, err := time.Parse(layout, text); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("invalid timestamp %q", text)
+	case bigquery.BytesFieldType:
+		b, err := hex.DecodeString(strings.TrimPrefix(text, `\x`))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea %q: %w", text, err)
+		}
+		return b, nil
+	default:
+		return text, nil
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	pqoid "github.com/lib/pq/oid"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// maxRecordDepth is the deepest nesting of RECORD fields BigQuery accepts.
+const maxRecordDepth = 15
+
+const postgresTypeQuery = `SELECT t.typtype, t.typcategory = 'A', t.typelem, t.typbasetype, t.typtypmod, t.typrelid,
+	format_type(t.oid, -1)
+	FROM pg_catalog.pg_type t
+	WHERE t.oid = $1`
+
+const postgresEnumQuery = `SELECT enumlabel FROM pg_catalog.pg_enum WHERE enumtypid = $1 ORDER BY enumsortorder`
+
+const postgresCompositeQuery = `SELECT attname, atttypid, atttypmod
+	FROM pg_catalog.pg_attribute
+	WHERE attrelid = $1 AND attnum > 0 AND NOT attisdropped
+	ORDER BY attnum`
+
+// postgresQueryColumnTypes returns the type OIDs of the named columns of a query, which the driver
+// only names for built-in types. The query is joined to a single row so that the types are
+// returned even when it has no rows. The column names must be valid BigQuery identifiers, which
+// need no escaping inside double quotes.
+func postgresQueryColumnTypes(db *sql.DB, query string, args []any, columns []string) ([]uint32, error) {
+	selects := make([]string, len(columns))
+	for i, column := range columns {
+		selects[i] = fmt.Sprintf(`pg_typeof(q."%s")::oid`, column)
+	}
+	typeQuery := fmt.Sprintf("SELECT %s FROM (SELECT 1) AS one LEFT JOIN (%s) AS q ON true LIMIT 1",
+		strings.Join(selects, ", "), query)
+
+	oids := make([]uint32, len(columns))
+	dest := make([]any, len(columns))
+	for i := range oids {
+		dest[i] = &oids[i]
+	}
+	if err := db.QueryRow(typeQuery, args...).Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to read query column types: %w", err)
+	}
+	return oids, nil
+}
+
+// resolvePostgresQueryTypes resolves the fields of a query's schema whose column types the driver
+// does not know, through pg_type.
+func resolvePostgresQueryTypes(db *sql.DB, query string, args []any, schema bigquery.Schema, logger *zap.Logger) error {
+	columns := make([]string, len(schema))
+	for i, field := range schema {
+		columns[i] = field.Name
+	}
+	oids, err := postgresQueryColumnTypes(db, query, args, columns)
+	if err != nil {
+		return err
+	}
+
+	for i, field := range schema {
+		if _, known := pqoid.TypeName[pqoid.Oid(oids[i])]; known {
+			continue
+		}
+		if err := resolvePostgresType(context.Background(), db, field, oids[i], -1, 1, logger); err != nil {
+			return err
+		}
+		logger.Debug("Resolved query column type",
+			zap.String("column_name", field.Name),
+			zap.Uint32("type_oid", oids[i]),
+			zap.String("bigquery_type", string(field.Type)+model.TypeParameters(field)),
+			zap.Bool("repeated", field.Repeated))
+	}
+	return nil
+}
+
+// resolvePostgresType sets the type of a field from a PostgreSQL type, looked up in pg_type by oid
+// with its type modifier (-1 = none):
+//
+//   - domains map like their base type
+//   - arrays map to REPEATED fields of their element type
+//   - enums map to STRING, with their allowed values appended to the field description
+//   - composite types map to RECORD fields with a nested field per attribute
+//   - other types map by name, keeping declared decimal precision and scale and character lengths
+//
+// depth is the nesting level of the field, 1 for a column.
+func resolvePostgresType(ctx context.Context, db *sql.DB, field *bigquery.FieldSchema, oid uint32, typmod int64, depth int, logger *zap.Logger) error {
+	var (
+		kind, name      string
+		isArray         bool
+		elem, base, rel uint32
+		baseTypmod      int64
+	)
+	err := db.QueryRowContext(ctx, postgresTypeQuery, oid).Scan(&kind, &isArray, &elem, &base, &baseTypmod, &rel, &name)
+	if err != nil {
+		return fmt.Errorf("failed to look up type %d of column '%s': %w", oid, field.Name, err)
+	}
+
+	switch {
+	case kind == "d":
+		return resolvePostgresType(ctx, db, field, base, baseTypmod, depth, logger)
+	case isArray:
+		// REPEATED fields cannot be REQUIRED.
+		field.Repeated = true
+		field.Required = false
+		return resolvePostgresType(ctx, db, field, elem, typmod, depth, logger)
+	case kind == "e":
+		labels, err := postgresEnumLabels(ctx, db, oid)
+		if err != nil {
+			return fmt.Errorf("failed to read values of enum %s: %w", name, err)
+		}
+		field.Type = bigquery.StringFieldType
+		allowed := "Allowed values: " + strings.Join(labels, ", ")
+		if field.Description != "" {
+			allowed = field.Description + "\n" + allowed
+		}
+		field.Description = truncateDescription(allowed)
+		return nil
+	case kind == "c":
+		if depth >= maxRecordDepth {
+			return fmt.Errorf("composite type %s of column '%s' is nested deeper than the %d levels BigQuery supports", name, field.Name, maxRecordDepth)
+		}
+		nested, err := postgresCompositeFields(ctx, db, rel, depth+1, logger)
+		if err != nil {
+			return fmt.Errorf("failed to read attributes of composite type %s: %w", name, err)
+		}
+		field.Type = bigquery.RecordFieldType
+		field.Schema = nested
+		return nil
+	}
+
+	col := catalogColumn{Name: field.Name, Type: strings.ToUpper(name)}
+	field.Type = postgresTypeToBigQueryType(col.Type, logger)
+	switch col.Type {
+	case "NUMERIC":
+		if typmod >= 4 {
+			col.Precision, col.Scale = ((typmod-4)>>16)&65535, (typmod-4)&65535
+		}
+		applyDecimalType(field, col, logger)
+	case "CHARACTER VARYING", "CHARACTER":
+		if typmod >= 4 {
+			field.MaxLength = typmod - 4
+		}
+	}
+	return nil
+}
+
+// postgresEnumLabels returns the values of an enum type in their sort order.
+func postgresEnumLabels(ctx context.Context, db *sql.DB, oid uint32) ([]string, error) {
+	rows, err := db.QueryContext(ctx, postgresEnumQuery, oid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []string
+	for rows.Next() {
+		var label string
+		if err := rows.Scan(&label); err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+	return labels, rows.Err()
+}
+
+// postgresCompositeFields returns the nested fields of a composite type, whose attributes are the
+// columns of its pg_class entry rel. Attributes cannot be declared NOT NULL, so none is REQUIRED.
+func postgresCompositeFields(ctx context.Context, db *sql.DB, rel uint32, depth int, logger *zap.Logger) (bigquery.Schema, error) {
+	type attribute struct {
+		name   string
+		oid    uint32
+		typmod int64
+	}
+	rows, err := db.QueryContext(ctx, postgresCompositeQuery, rel)
+	if err != nil {
+		return nil, err
+	}
+	var attributes []attribute
+	for rows.Next() {
+		var a attribute
+		if err := rows.Scan(&a.name, &a.oid, &a.typmod); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		attributes = append(attributes, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// The attributes are resolved once the rows are closed, so their lookups do not need a
+	// second connection.
+	schema := make(bigquery.Schema, 0, len(attributes))
+	for _, a := range attributes {
+		if err := validateBigQueryIdentifier(a.name, "Composite attribute name"); err != nil {
+			return nil, err
+		}
+		field := &bigquery.FieldSchema{Name: a.name}
+		if err := resolvePostgresType(ctx, db, field, a.oid, a.typmod, depth, logger); err != nil {
+			return nil, err
+		}
+		schema = append(schema, field)
+	}
+	return schema, nil
+}
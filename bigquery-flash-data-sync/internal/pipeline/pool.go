@@ -0,0 +1,133 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+	"golang.org/x/sync/semaphore"
+)
+
+// sourcePool is shared by all tables of one source database: a single connection pool,
+// opened on first use, the database's consistent snapshot, begun on first use, and a limit on
+// how many of the database's tables sync at once.
+type sourcePool struct {
+	dbConfig *model.DatabaseConfig
+	slots    *semaphore.Weighted // nil means no per-database limit
+
+	mu       sync.Mutex
+	db       *sql.DB
+	snapshot *consistentSnapshot
+}
+
+// newSourcePool creates the pool of a source database. A limit <= 0 disables the per-database limit.
+func newSourcePool(dbConfig *model.DatabaseConfig, limit int) *sourcePool {
+	return &sourcePool{
+		dbConfig: dbConfig,
+		slots:    newLimiter(limit),
+	}
+}
+
+// open returns the shared connection pool of the database, opening it on the first successful call.
+// A failed open is not remembered, so a later call (or a retry) tries again.
+func (p *sourcePool) open(ctx context.Context, cfg *model.Config, logger *zap.Logger) (*sql.DB, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.db == nil {
+		db, err := openDatabaseConnection(ctx, p.dbConfig, cfg, logger)
+		if err != nil {
+			return nil, err
+		}
+		p.db = db
+	}
+	return p.db, nil
+}
+
+// consistentSnapshot returns the consistent snapshot all tables of the database are read from,
+// beginning it on the first successful call. Like open, a failed attempt is not remembered.
+func (p *sourcePool) consistentSnapshot(ctx context.Context, db *sql.DB, logger *zap.Logger) (*consistentSnapshot, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.snapshot == nil {
+		snapshot, err := beginConsistentSnapshot(ctx, db, p.dbConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+		p.snapshot = snapshot
+	}
+	return p.snapshot, nil
+}
+
+// Close ends the consistent snapshot and closes the connection pool if they were opened.
+func (p *sourcePool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.snapshot != nil {
+		p.snapshot.Close()
+		p.snapshot = nil
+	}
+	if p.db != nil {
+		_ = p.db.Close()
+		p.db = nil
+	}
+}
+
+// acquireTableSlot blocks until a table of this database may start syncing under both the
+// per-database and the global limit, or ctx is done. The returned function releases the slot.
+func (p *sourcePool) acquireTableSlot(ctx context.Context, global *semaphore.Weighted) (func(), error) {
+	// Take the per-database slot first so that tables waiting on a busy database
+	// never hold a global slot that tables of other databases could use.
+	if err := acquire(ctx, p.slots); err != nil {
+		return nil, err
+	}
+	if err := acquire(ctx, global); err != nil {
+		release(p.slots)
+		return nil, err
+	}
+	return func() {
+		release(global)
+		release(p.slots)
+	}, nil
+}
+
+// newLimiter returns a semaphore admitting limit holders, or nil for no limit when limit <= 0.
+func newLimiter(limit int) *semaphore.Weighted {
+	if limit <= 0 {
+		return nil
+	}
+	return semaphore.NewWeighted(int64(limit))
+}
+
+func acquire(ctx context.Context, s *semaphore.Weighted) error {
+	if s == nil {
+		return nil
+	}
+	return s.Acquire(ctx, 1)
+}
+
+func release(s *semaphore.Weighted) {
+	if s != nil {
+		s.Release(1)
+	}
+}
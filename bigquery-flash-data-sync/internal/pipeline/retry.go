@@ -0,0 +1,191 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+	"google.golang.org/api/googleapi"
+)
+
+// retrier retries transient failures of the operations of one table with exponential backoff
+// and jitter, and counts the retries for the table's SyncResult.
+type retrier struct {
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	logger         *zap.Logger
+
+	retries atomic.Int64
+}
+
+// newRetrier creates a retrier from the configured retry policy.
+func newRetrier(cfg *model.Config, logger *zap.Logger) *retrier {
+	maxAttempts := cfg.RetryMaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	return &retrier{
+		maxAttempts:    maxAttempts,
+		initialBackoff: cfg.RetryInitialBackoff,
+		maxBackoff:     cfg.RetryMaxBackoff,
+		logger:         logger,
+	}
+}
+
+// Retries returns the number of retries performed so far.
+func (r *retrier) Retries() int64 {
+	return r.retries.Load()
+}
+
+// do runs op until it succeeds, fails with a permanent error, or the attempts are exhausted.
+// op must be safe to run again after a transient failure.
+func (r *retrier) do(ctx context.Context, operation string, op func(context.Context) error) error {
+	return r.doIf(ctx, operation, op, func(error) bool { return true })
+}
+
+// doIf is like do, but a transient failure is only retried when replayable reports that running
+// op again cannot duplicate or lose data, e.g. because the failed attempt committed nothing.
+func (r *retrier) doIf(ctx context.Context, operation string, op func(context.Context) error, replayable func(error) bool) error {
+	for attempt := 1; ; attempt++ {
+		err := op(ctx)
+		if err == nil {
+			return nil
+		}
+		if attempt >= r.maxAttempts || !isTransientError(err) || !replayable(err) {
+			return err
+		}
+
+		delay := r.backoff(attempt)
+		r.retries.Add(1)
+		r.logger.Warn("Transient failure, retrying",
+			zap.String("operation", operation),
+			zap.Int("attempt", attempt),
+			zap.Int("max_attempts", r.maxAttempts),
+			zap.Duration("backoff", delay),
+			zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the given retry: the initial backoff doubled per attempt,
+// capped at the maximum, with equal jitter so concurrent tables do not retry in lockstep.
+func (r *retrier) backoff(attempt int) time.Duration {
+	d := r.initialBackoff
+	for i := 1; i < attempt && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if r.maxBackoff > 0 && d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// transientBigQueryReasons are the BigQuery error reasons that indicate a temporary failure.
+var transientBigQueryReasons = map[string]bool{
+	"backendError":      true,
+	"internalError":     true,
+	"rateLimitExceeded": true,
+	"jobBackendError":   true,
+	"jobInternalError":  true,
+}
+
+// isTransientError reports whether err is a temporary failure of the source database, the network
+// or BigQuery that is likely to succeed when retried. Everything else is treated as permanent.
+func isTransientError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// MySQL: deadlock, lock wait timeout and lost connections.
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1213 || myErr.Number == 1205
+	}
+	if errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+
+	// PostgreSQL: serialization failures, deadlocks, shutdowns and connection exceptions.
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", "40P01", "53300", "57P01", "57P02", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+
+	// BigQuery: job failures and API errors.
+	var bqErr *bigquery.Error
+	if errors.As(err, &bqErr) {
+		return transientBigQueryReasons[bqErr.Reason]
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.Code {
+		case 429, 500, 502, 503, 504:
+			return true
+		}
+		for _, item := range apiErr.Errors {
+			if transientBigQueryReasons[item.Reason] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Network: dropped connections and timeouts.
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	// Some drivers and transports flatten the cause into the message.
+	msg := err.Error()
+	return strings.Contains(msg, "connection reset by peer") || strings.Contains(msg, "broken pipe")
+}
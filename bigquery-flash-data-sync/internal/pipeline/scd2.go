@@ -0,0 +1,105 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// applyHistoryFromStaging applies a staging table to an SCD Type 2 history table: the current
+// version of every staged key whose _row_hash changed is closed, and a new current version is
+// inserted for it and for every new key. Unchanged rows are left alone. Both statements run in one
+// transaction, so readers never see a key without its current version.
+func applyHistoryFromStaging(ctx context.Context, client *bigquery.Client, cfg *model.Config, staging, target string, schema bigquery.Schema, keys []string, orderBy string, logger *zap.Logger) error {
+	sql, err := buildHistoryStatement(cfg, staging, target, schema, keys, orderBy)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Applying staging table to history table",
+		zap.String("staging_table", staging),
+		zap.Strings("primary_key", keys),
+		zap.String("statement", sql))
+
+	if err := runBigQueryStatement(ctx, client, sql, nil); err != nil {
+		return fmt.Errorf("failed to apply staging table '%s' to history table '%s': %w", staging, target, err)
+	}
+	return nil
+}
+
+// buildHistoryStatement builds the script used by applyHistoryFromStaging. schema is the schema of
+// the staged rows, including their _row_hash.
+func buildHistoryStatement(cfg *model.Config, staging, target string, schema bigquery.Schema, keys []string, orderBy string) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("history requires at least one primary key column")
+	}
+
+	fields := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = true
+	}
+	if !fields[rowHashColumn] {
+		return "", fmt.Errorf("history requires the %s column", rowHashColumn)
+	}
+
+	quotedKeys := make([]string, 0, len(keys))
+	joins := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !fields[key] {
+			return "", fmt.Errorf("primary key column %q not found in source schema", key)
+		}
+		quotedKeys = append(quotedKeys, quoteBigQueryIdentifier(key))
+		joins = append(joins, fmt.Sprintf("T.%s = S.%s", quoteBigQueryIdentifier(key), quoteBigQueryIdentifier(key)))
+	}
+
+	columns := make([]string, 0, len(schema)+3)
+	values := make([]string, 0, len(schema)+3)
+	for _, field := range schema {
+		col := quoteBigQueryIdentifier(field.Name)
+		columns = append(columns, col)
+		values = append(values, "S."+col)
+	}
+	columns = append(columns,
+		quoteBigQueryIdentifier(validFromColumn), quoteBigQueryIdentifier(validToColumn), quoteBigQueryIdentifier(isCurrentColumn))
+	values = append(values, "_datasync_time", "NULL", "TRUE")
+
+	if orderBy == "" {
+		orderBy = strings.Join(quotedKeys, ", ")
+	}
+	source := fmt.Sprintf("(\n  SELECT * FROM %s\n  WHERE TRUE\n  QUALIFY ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) = 1\n) S",
+		bigQueryTableRef(cfg, staging), strings.Join(quotedKeys, ", "), orderBy)
+	targetRef := bigQueryTableRef(cfg, target)
+	hash := quoteBigQueryIdentifier(rowHashColumn)
+	isCurrent := quoteBigQueryIdentifier(isCurrentColumn)
+	on := strings.Join(joins, " AND ")
+
+	var b strings.Builder
+	b.WriteString("DECLARE _datasync_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP();\n")
+	b.WriteString("BEGIN TRANSACTION;\n")
+	fmt.Fprintf(&b, "UPDATE %s T\nSET %s = _datasync_time, %s = FALSE\nFROM %s\nWHERE %s AND T.%s AND T.%s IS DISTINCT FROM S.%s;\n",
+		targetRef, quoteBigQueryIdentifier(validToColumn), isCurrent, source, on, isCurrent, hash, hash)
+	fmt.Fprintf(&b, "INSERT INTO %s (%s)\nSELECT %s\nFROM %s\nWHERE NOT EXISTS (SELECT 1 FROM %s T WHERE %s AND T.%s);\n",
+		targetRef, strings.Join(columns, ", "), strings.Join(values, ", "), source, targetRef, on, isCurrent)
+	b.WriteString("COMMIT TRANSACTION;")
+	return b.String(), nil
+}
@@ -0,0 +1,157 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// validSnapshotID matches the snapshot identifiers returned by pg_export_snapshot().
+var validSnapshotID = regexp.MustCompile(`^[0-9A-Fa-f-]+$`)
+
+// consistentSnapshot is a point-in-time view of a source database that all its tables are read
+// from, so that rows referencing each other across tables line up in BigQuery. It is held open by
+// a transaction on a dedicated connection until Close.
+//
+// MySQL cannot share a snapshot between connections, so every read goes through the connection
+// holding the START TRANSACTION WITH CONSISTENT SNAPSHOT transaction, one query at a time.
+// PostgreSQL exports the snapshot of a REPEATABLE READ transaction, which every read imports into
+// a transaction of its own, so reads still run in parallel.
+type consistentSnapshot struct {
+	dbType string
+	conn   *sql.Conn // Connection holding the snapshot open
+	id     string    // Exported snapshot (postgres only)
+
+	mu sync.Mutex // Serializes reads through conn (mysql only)
+}
+
+// beginConsistentSnapshot opens a connection from db and starts the transaction holding the snapshot.
+func beginConsistentSnapshot(ctx context.Context, db *sql.DB, dbConfig *model.DatabaseConfig, logger *zap.Logger) (*consistentSnapshot, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot connection: %w", err)
+	}
+
+	s := &consistentSnapshot{dbType: strings.ToLower(dbConfig.Type), conn: conn}
+	switch s.dbType {
+	case "mysql":
+		// Applies to the next transaction only, whatever the session default is.
+		if _, err = conn.ExecContext(ctx, "SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err == nil {
+			_, err = conn.ExecContext(ctx, "START TRANSACTION WITH CONSISTENT SNAPSHOT, READ ONLY")
+		}
+	case "postgres":
+		if _, err = conn.ExecContext(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY"); err == nil {
+			err = conn.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&s.id)
+		}
+		if err == nil && !validSnapshotID.MatchString(s.id) {
+			err = fmt.Errorf("unexpected snapshot identifier %q", s.id)
+		}
+	default:
+		err = fmt.Errorf("consistent snapshots are not supported for database type '%s'", dbConfig.Type)
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed to begin consistent snapshot: %w", err)
+	}
+
+	logger.Info("Consistent snapshot started, all tables of the database are read from it",
+		zap.String("database", dbConfig.Name),
+		zap.String("snapshot_id", s.id))
+	return s, nil
+}
+
+// query runs a query inside the snapshot. The returned function must be called once the rows
+// are closed.
+func (s *consistentSnapshot) query(ctx context.Context, db *sql.DB, query string, args ...any) (*sql.Rows, func(), error) {
+	if s.dbType == "mysql" {
+		s.mu.Lock()
+		rows, err := s.conn.QueryContext(ctx, query, args...)
+		if err != nil {
+			s.mu.Unlock()
+			return nil, nil, err
+		}
+		return rows, s.mu.Unlock, nil
+	}
+
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin snapshot transaction: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET TRANSACTION SNAPSHOT '%s'", s.id)); err != nil {
+		_ = tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to import snapshot %s: %w", s.id, err)
+	}
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		_ = tx.Rollback()
+		return nil, nil, err
+	}
+	return rows, func() { _ = tx.Rollback() }, nil
+}
+
+// Close ends the transaction holding the snapshot and returns its connection to the pool.
+func (s *consistentSnapshot) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, _ = s.conn.ExecContext(context.Background(), "ROLLBACK")
+	_ = s.conn.Close()
+}
+
+// sourceReader runs the queries reading a table from its source database: through the connection
+// pool, or inside the database's consistent snapshot when it has one.
+type sourceReader struct {
+	db       *sql.DB
+	snapshot *consistentSnapshot // nil = read the current state of the database
+}
+
+// query runs a query returning rows. The returned function must be called once the rows are closed.
+func (r *sourceReader) query(ctx context.Context, query string, args ...any) (*sql.Rows, func(), error) {
+	if r.snapshot != nil {
+		return r.snapshot.query(ctx, r.db, query, args...)
+	}
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	return rows, func() {}, err
+}
+
+// queryRow runs a query returning at most one row and scans it into dest.
+func (r *sourceReader) queryRow(ctx context.Context, query string, args []any, dest ...any) error {
+	rows, done, err := r.query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return rows.Close()
+}
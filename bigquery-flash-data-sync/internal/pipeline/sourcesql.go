@@ -0,0 +1,240 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+)
+
+// customQueryAlias is the alias of a table's custom QUERY in the FROM clause of the source queries.
+const customQueryAlias = "src"
+
+// sourceParams holds the values the source queries of a table are built with.
+type sourceParams struct {
+	Window       *watermarkWindow // Incremental window (nil = every row)
+	RunStartedAt time.Time
+}
+
+// values returns the values of the named parameters a custom QUERY or WHERE may use:
+//
+//	:watermark       stored high-water mark minus the overlap, NULL on a first or non-incremental sync
+//	:run_started_at  when the pipeline run started
+func (p sourceParams) values() map[string]any {
+	var watermark any
+	if p.Window != nil && p.Window.HasFrom {
+		watermark = p.Window.From
+	}
+	return map[string]any{
+		"watermark":      watermark,
+		"run_started_at": p.RunStartedAt.UTC(),
+	}
+}
+
+// sourceSelection returns the FROM item the rows of a table are read from and the conditions that
+// restrict them: the source table, or the table's custom QUERY as a derived table, filtered by its
+// WHERE. Named parameters are bound to the returned arguments, with placeholders numbered from
+// argOffset+1, so the selection can be combined with other conditions.
+func sourceSelection(dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, params sourceParams, argOffset int) (string, []string, []any, error) {
+	values := params.values()
+
+	var from string
+	var args []any
+	if tableConfig.Query != "" {
+		query, queryArgs, err := bindNamedParameters(dbConfig.Type, tableConfig.Query, values, argOffset)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("invalid QUERY: %w", err)
+		}
+		from = fmt.Sprintf("(%s) %s", query, customQueryAlias)
+		args = append(args, queryArgs...)
+	} else {
+		tableRef, err := sourceTableRef(dbConfig, tableConfig)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		from = tableRef
+	}
+
+	var conditions []string
+	if tableConfig.Where != "" {
+		where, whereArgs, err := bindNamedParameters(dbConfig.Type, tableConfig.Where, values, argOffset+len(args))
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("invalid WHERE: %w", err)
+		}
+		conditions = append(conditions, "("+where+")")
+		args = append(args, whereArgs...)
+	}
+	return from, conditions, args, nil
+}
+
+// bindNamedParameters replaces the :name parameters of a custom SQL fragment with bind placeholders
+// of the database type, numbered from argOffset+1, and returns their values in placeholder order.
+// String literals (including PostgreSQL $tag$ dollar-quoted strings), quoted identifiers, comments
+// and PostgreSQL :: casts are left untouched. The
+// fragment must be a single statement, and positional placeholders are rejected because their
+// values could not be bound.
+func bindNamedParameters(dbType, text string, values map[string]any, argOffset int) (string, []any, error) {
+	dbType = strings.ToLower(dbType)
+
+	var b strings.Builder
+	var args []any
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end, err := closingQuote(dbType, text, i)
+			if err != nil {
+				return "", nil, err
+			}
+			b.WriteString(text[i : end+1])
+			i = end + 1
+		case isLineComment(dbType, text[i:]):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				// The fragment is embedded in a larger query: end the comment before what follows it.
+				b.WriteString(text[i:] + "\n")
+				i = len(text)
+				continue
+			}
+			b.WriteString(text[i : i+end])
+			i += end
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated comment")
+			}
+			b.WriteString(text[i : i+end+4])
+			i += end + 4
+		case c == '$' && dbType == "postgres" && (i == 0 || !isIdentifierPart(text[i-1])) && dollarQuoteTag(text[i:]) != "":
+			tag := dollarQuoteTag(text[i:])
+			end := strings.Index(text[i+len(tag):], tag)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated dollar-quoted string starting at offset %d", i)
+			}
+			end += i + 2*len(tag)
+			b.WriteString(text[i:end])
+			i = end
+		case c == ';':
+			return "", nil, fmt.Errorf("must be a single statement without ';'")
+		case c == '?' && dbType != "postgres":
+			return "", nil, fmt.Errorf("positional placeholder '?' is not supported, use a named parameter (%s)", strings.Join(parameterNames(values), ", "))
+		case c == '$' && dbType == "postgres" && i+1 < len(text) && isDigit(text[i+1]):
+			return "", nil, fmt.Errorf("positional placeholder '$n' is not supported, use a named parameter (%s)", strings.Join(parameterNames(values), ", "))
+		case strings.HasPrefix(text[i:], "::"):
+			b.WriteString("::")
+			i += 2
+		case c == ':' && i+1 < len(text) && isIdentifierStart(text[i+1]):
+			end := i + 1
+			for end < len(text) && (isIdentifierStart(text[end]) || isDigit(text[end])) {
+				end++
+			}
+			name := text[i+1 : end]
+			value, ok := values[name]
+			if !ok {
+				return "", nil, fmt.Errorf("unknown parameter :%s, expected one of %s", name, strings.Join(parameterNames(values), ", "))
+			}
+			args = append(args, value)
+			b.WriteString(sqlPlaceholder(dbType, argOffset+len(args)))
+			i = end
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String(), args, nil
+}
+
+// closingQuote returns the index of the quote closing the literal or quoted identifier opened at
+// text[start]. A doubled quote is part of the literal; backslashes escape the next character in
+// MySQL strings and in PostgreSQL E'...' strings.
+func closingQuote(dbType, text string, start int) (int, error) {
+	quote := text[start]
+	backslashEscapes := quote != '`' && dbType != "postgres"
+	if dbType == "postgres" && quote == '\'' && start > 0 && (text[start-1] == 'E' || text[start-1] == 'e') {
+		backslashEscapes = true
+	}
+
+	for i := start + 1; i < len(text); i++ {
+		switch {
+		case backslashEscapes && text[i] == '\\':
+			i++
+		case text[i] == quote:
+			if i+1 < len(text) && text[i+1] == quote {
+				i++
+				continue
+			}
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string starting at offset %d", start)
+}
+
+// isLineComment reports whether text starts with a comment that runs to the end of the line. MySQL
+// also starts one with #, and requires whitespace or a control character after --, so that a--1
+// is a subtraction.
+func isLineComment(dbType, text string) bool {
+	if dbType == "postgres" {
+		return strings.HasPrefix(text, "--")
+	}
+	if strings.HasPrefix(text, "#") {
+		return true
+	}
+	return strings.HasPrefix(text, "--") && (len(text) == 2 || text[2] <= ' ' || text[2] == 0x7f)
+}
+
+// dollarQuoteTag returns the opening delimiter ($$ or $tag$) of a PostgreSQL dollar-quoted string
+// at the start of text, or "" if text does not start with one.
+func dollarQuoteTag(text string) string {
+	for i := 1; i < len(text); i++ {
+		switch {
+		case text[i] == '$':
+			return text[:i+1]
+		case isIdentifierStart(text[i]), i > 1 && isDigit(text[i]):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// parameterNames returns the names of the supported named parameters, for error messages.
+func parameterNames(values map[string]any) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, ":"+name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isIdentifierStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isIdentifierPart reports whether c may continue an unquoted identifier; PostgreSQL allows $ after
+// the first character.
+func isIdentifierPart(c byte) bool {
+	return isIdentifierStart(c) || isDigit(c) || c == '$'
+}
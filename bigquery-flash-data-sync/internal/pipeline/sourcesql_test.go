@@ -0,0 +1,202 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBindNamedParameters(t *testing.T) {
+	values := map[string]any{"watermark": "w", "run_started_at": "r"}
+
+	tests := []struct {
+		name      string
+		dbType    string
+		text      string
+		argOffset int
+		want      string
+		wantArgs  []any
+		wantErr   string
+	}{
+		{
+			name:     "mysql parameters",
+			dbType:   "mysql",
+			text:     "updated_at > :watermark AND updated_at <= :run_started_at",
+			want:     "updated_at > ? AND updated_at <= ?",
+			wantArgs: []any{"w", "r"},
+		},
+		{
+			name:      "postgres placeholders numbered from offset",
+			dbType:    "postgres",
+			text:      "a > :watermark OR b = :watermark",
+			argOffset: 2,
+			want:      "a > $3 OR b = $4",
+			wantArgs:  []any{"w", "w"},
+		},
+		{
+			name:   "string literal and quoted identifier",
+			dbType: "postgres",
+			text:   `note = 'at :watermark' AND "col:x" = 'it''s :run_started_at'`,
+			want:   `note = 'at :watermark' AND "col:x" = 'it''s :run_started_at'`,
+		},
+		{
+			name:   "mysql backslash escape",
+			dbType: "mysql",
+			text:   `note = 'a\' :watermark' AND ` + "`x:y` = 1",
+			want:   `note = 'a\' :watermark' AND ` + "`x:y` = 1",
+		},
+		{
+			name:     "postgres E string",
+			dbType:   "postgres",
+			text:     `note = E'a\' :watermark' AND t > :watermark`,
+			want:     `note = E'a\' :watermark' AND t > $1`,
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "postgres cast",
+			dbType:   "postgres",
+			text:     ":watermark::timestamptz IS NULL",
+			want:     "$1::timestamptz IS NULL",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "comments",
+			dbType:   "mysql",
+			text:     "/* :watermark */ a = 1 -- :run_started_at\nAND b > :watermark",
+			want:     "/* :watermark */ a = 1 -- :run_started_at\nAND b > ?",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "mysql hash comment",
+			dbType:   "mysql",
+			text:     "a = 1 # :watermark ; ?\nAND b > :watermark",
+			want:     "a = 1 # :watermark ; ?\nAND b > ?",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "postgres hash is an operator",
+			dbType:   "postgres",
+			text:     "a # 1 = :watermark",
+			want:     "a # 1 = $1",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "mysql double dash without space is not a comment",
+			dbType:   "mysql",
+			text:     "a--1 > :watermark",
+			want:     "a--1 > ?",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:   "mysql double dash followed by a tab",
+			dbType: "mysql",
+			text:   "a = 1 --\t:watermark",
+			want:   "a = 1 --\t:watermark\n",
+		},
+		{
+			name:   "postgres double dash without space",
+			dbType: "postgres",
+			text:   "a = 1 --:watermark",
+			want:   "a = 1 --:watermark\n",
+		},
+		{
+			name:   "trailing line comment ends before what follows",
+			dbType: "mysql",
+			text:   "a = 1 -- :watermark",
+			want:   "a = 1 -- :watermark\n",
+		},
+		{
+			name:     "postgres dollar quotes",
+			dbType:   "postgres",
+			text:     "body = $$ :watermark ' $$ AND x = $fn$ :run_started_at $$ $fn$ AND t > :watermark",
+			want:     "body = $$ :watermark ' $$ AND x = $fn$ :run_started_at $$ $fn$ AND t > $1",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:     "dollar sign inside postgres identifier",
+			dbType:   "postgres",
+			text:     "a$b$ > :watermark",
+			want:     "a$b$ > $1",
+			wantArgs: []any{"w"},
+		},
+		{
+			name:    "unterminated dollar quote",
+			dbType:  "postgres",
+			text:    "body = $tag$ :watermark",
+			wantErr: "unterminated dollar-quoted string",
+		},
+		{
+			name:    "unterminated string",
+			dbType:  "mysql",
+			text:    "a = 'x",
+			wantErr: "unterminated quoted string",
+		},
+		{
+			name:    "unterminated comment",
+			dbType:  "mysql",
+			text:    "a = 1 /* x",
+			wantErr: "unterminated comment",
+		},
+		{
+			name:    "multiple statements",
+			dbType:  "mysql",
+			text:    "a = 1; DROP TABLE t",
+			wantErr: "single statement",
+		},
+		{
+			name:    "mysql positional placeholder",
+			dbType:  "mysql",
+			text:    "a = ?",
+			wantErr: "positional placeholder '?'",
+		},
+		{
+			name:    "postgres positional placeholder",
+			dbType:  "postgres",
+			text:    "a = $1",
+			wantErr: "positional placeholder '$n'",
+		},
+		{
+			name:    "unknown parameter",
+			dbType:  "mysql",
+			text:    "a = :since",
+			wantErr: "unknown parameter :since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, args, err := bindNamedParameters(tt.dbType, tt.text, values, tt.argOffset)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("text = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
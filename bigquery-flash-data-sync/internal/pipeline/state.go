@@ -0,0 +1,162 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+	"google.golang.org/api/iterator"
+)
+
+// syncStateSchema is the schema of the sync state table kept in the target dataset.
+// There is one row per (database_name, source_table).
+var syncStateSchema = bigquery.Schema{
+	{Name: "database_name", Type: bigquery.StringFieldType, Required: true},
+	{Name: "source_table", Type: bigquery.StringFieldType, Required: true},
+	{Name: "watermark", Type: bigquery.TimestampFieldType},
+	{Name: "cdc_position", Type: bigquery.StringFieldType},
+	{Name: "updated_at", Type: bigquery.TimestampFieldType, Required: true},
+}
+
+// ensureSyncStateTable creates the sync state table if it does not exist yet.
+func ensureSyncStateTable(ctx context.Context, client *bigquery.Client, cfg *model.Config, logger *zap.Logger) error {
+	_, err := createOrUpdateTable(ctx, client, cfg.BigQueryDatasetID, model.BQTable{
+		Name:   cfg.StateTable,
+		Schema: syncStateSchema,
+	}, model.SchemaPolicyEvolve, logger)
+	return err
+}
+
+// loadWatermark reads the stored high-water mark for a source table.
+// The boolean result is false when no watermark has been recorded yet
+// (including when the state table itself does not exist).
+func loadWatermark(ctx context.Context, client *bigquery.Client, cfg *model.Config, dbName, tableName string) (time.Time, bool, error) {
+	q := client.Query(fmt.Sprintf(
+		"SELECT watermark FROM %s WHERE database_name = @database_name AND source_table = @source_table AND watermark IS NOT NULL LIMIT 1",
+		bigQueryTableRef(cfg, cfg.StateTable),
+	))
+	q.Parameters = stateKeyParams(dbName, tableName)
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		if isNotFoundError(err) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, fmt.Errorf("failed to read watermark: %w", err)
+	}
+
+	var row []bigquery.Value
+	if err := it.Next(&row); err != nil {
+		if errors.Is(err, iterator.Done) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, fmt.Errorf("failed to read watermark: %w", err)
+	}
+
+	watermark, ok := row[0].(time.Time)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("unexpected watermark value type %T", row[0])
+	}
+	return watermark, true, nil
+}
+
+// saveWatermark records a new high-water mark for a source table, inserting the
+// state row on first use.
+func saveWatermark(ctx context.Context, client *bigquery.Client, cfg *model.Config, dbName, tableName string, watermark time.Time) error {
+	sql := fmt.Sprintf(`MERGE %s T
+USING (SELECT @database_name AS database_name, @source_table AS source_table) S
+ON T.database_name = S.database_name AND T.source_table = S.source_table
+WHEN MATCHED THEN
+  UPDATE SET watermark = @watermark, updated_at = CURRENT_TIMESTAMP()
+WHEN NOT MATCHED THEN
+  INSERT (database_name, source_table, watermark, updated_at)
+  VALUES (S.database_name, S.source_table, @watermark, CURRENT_TIMESTAMP())`,
+		bigQueryTableRef(cfg, cfg.StateTable))
+
+	params := append(stateKeyParams(dbName, tableName), bigquery.QueryParameter{Name: "watermark", Value: watermark})
+	if err := runBigQueryStatement(ctx, client, sql, params); err != nil {
+		return fmt.Errorf("failed to save watermark: %w", err)
+	}
+	return nil
+}
+
+// loadCDCPosition reads the stored change log position of a source table. The position is an
+// opaque string owned by the change log reader of the source type.
+// The boolean result is false when no position has been recorded yet.
+func loadCDCPosition(ctx context.Context, client *bigquery.Client, cfg *model.Config, dbName, tableName string) (string, bool, error) {
+	q := client.Query(fmt.Sprintf(
+		"SELECT cdc_position FROM %s WHERE database_name = @database_name AND source_table = @source_table AND cdc_position IS NOT NULL LIMIT 1",
+		bigQueryTableRef(cfg, cfg.StateTable),
+	))
+	q.Parameters = stateKeyParams(dbName, tableName)
+
+	it, err := q.Read(ctx)
+	if err != nil {
+		if isNotFoundError(err) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to read change log position: %w", err)
+	}
+
+	var row []bigquery.Value
+	if err := it.Next(&row); err != nil {
+		if errors.Is(err, iterator.Done) {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("failed to read change log position: %w", err)
+	}
+
+	position, ok := row[0].(string)
+	if !ok {
+		return "", false, fmt.Errorf("unexpected change log position value type %T", row[0])
+	}
+	return position, true, nil
+}
+
+// saveCDCPosition records the change log position up to which the changes of a source table
+// have been applied, inserting the state row on first use.
+func saveCDCPosition(ctx context.Context, client *bigquery.Client, cfg *model.Config, dbName, tableName, position string) error {
+	sql := fmt.Sprintf(`MERGE %s T
+USING (SELECT @database_name AS database_name, @source_table AS source_table) S
+ON T.database_name = S.database_name AND T.source_table = S.source_table
+WHEN MATCHED THEN
+  UPDATE SET cdc_position = @cdc_position, updated_at = CURRENT_TIMESTAMP()
+WHEN NOT MATCHED THEN
+  INSERT (database_name, source_table, cdc_position, updated_at)
+  VALUES (S.database_name, S.source_table, @cdc_position, CURRENT_TIMESTAMP())`,
+		bigQueryTableRef(cfg, cfg.StateTable))
+
+	params := append(stateKeyParams(dbName, tableName), bigquery.QueryParameter{Name: "cdc_position", Value: position})
+	if err := runBigQueryStatement(ctx, client, sql, params); err != nil {
+		return fmt.Errorf("failed to save change log position: %w", err)
+	}
+	return nil
+}
+
+// stateKeyParams returns the query parameters identifying a row in the sync state table.
+func stateKeyParams(dbName, tableName string) []bigquery.QueryParameter {
+	return []bigquery.QueryParameter{
+		{Name: "database_name", Value: dbName},
+		{Name: "source_table", Value: tableName},
+	}
+}
@@ -0,0 +1,369 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// rowEncodeError is returned by loadStream.Write when a single row cannot be encoded as JSON.
+// Nothing is written for the row, so the stream remains usable.
+type rowEncodeError struct {
+	err error
+}
+
+func (e *rowEncodeError) Error() string {
+	return fmt.Sprintf("failed to encode row: %v", e.err)
+}
+
+func (e *rowEncodeError) Unwrap() error {
+	return e.err
+}
+
+// segmentMode describes how the rows of the current load job segment are handled.
+type segmentMode int
+
+const (
+	segmentUpload    segmentMode = iota // Rows are streamed into a newly submitted load job
+	segmentCommitted                    // A previous attempt already loaded the segment; rows are discarded
+	segmentAttached                     // A previous attempt's load job is still running; rows are discarded and the job awaited
+)
+
+// loadStream streams NDJSON-encoded rows into BigQuery load jobs through an io.Pipe. Rows are
+// uploaded while they are extracted instead of being buffered, so memory stays flat no matter how
+// large the table is. A single load job receives all rows until it reaches the configured row or
+// byte limit, at which point it is completed and the next row starts a new load job.
+//
+// When the job has a LoadJobIDPrefix, every load job (segment) gets a deterministic job ID made of
+// the prefix and the segment's sequence number, and the job's SegmentSealed callback records the
+// last row of each segment. A replay after an ambiguous failure continues after the last row of the
+// last segment that committed (see extractWithRetry), so it loads every row exactly once even while
+// the source table changes.
+//
+// Before a segment is submitted, the job ID is looked up: a segment that already succeeded is
+// skipped, a segment that is still running is awaited, and only a segment whose job never ran (or
+// failed) is submitted. Rows of a skipped segment are discarded by count, which is only exact if the
+// rows it covered did not change. That happens when a --resume run finds that the load job after the
+// last checkpoint committed before the interrupted process could save its checkpoint.
+//
+// When the job has a Checkpoint callback, it is called with the last row of every load job once
+// the load job succeeded, so an interrupted sync can later continue after that row.
+type loadStream struct {
+	ctx           context.Context
+	client        *bigquery.Client
+	datasetID     string
+	table         string
+	truncateFirst bool
+	maxRows       int64  // 0 means no limit
+	maxBytes      int64  // 0 means no limit
+	jobIDPrefix   string // empty means BigQuery generates random job IDs
+	checkpoint    func(loadJobs int, rows int64, lastRow map[string]any) error
+	sealed        func(seq int, jobID string, rows int64, lastRow map[string]any)
+	logger        *zap.Logger
+
+	active   bool
+	mode     segmentMode
+	jobID    string
+	pw       *io.PipeWriter
+	done     chan error
+	attached *bigquery.Job
+	lastRow  map[string]any
+
+	seq       int
+	jobRows   int64
+	jobBytes  int64
+	jobs      int
+	rowsDone  int64
+	bytesDone int64
+}
+
+// newLoadStream creates a stream that loads the rows of a job into job.LoadTable. When
+// job.TruncateFirstLoad is set, the first load job replaces the contents of the table and
+// later ones append to it. A job resumed from a checkpoint continues the load job sequence and
+// row count after the committed load jobs.
+func newLoadStream(ctx context.Context, client *bigquery.Client, datasetID string, job model.Job, logger *zap.Logger) *loadStream {
+	return &loadStream{
+		ctx:           ctx,
+		client:        client,
+		datasetID:     datasetID,
+		table:         job.LoadTable,
+		truncateFirst: job.TruncateFirstLoad,
+		maxRows:       int64(job.BatchSize),
+		maxBytes:      job.MaxLoadBytes,
+		jobIDPrefix:   job.LoadJobIDPrefix,
+		checkpoint:    job.Checkpoint,
+		sealed:        job.SegmentSealed,
+		logger:        logger,
+		seq:           job.CommittedLoadJobs,
+		jobs:          job.CommittedLoadJobs,
+		rowsDone:      job.CommittedRows,
+	}
+}
+
+// Write encodes a row into the current load job, starting a new load job if none is running.
+// A *rowEncodeError means only this row was rejected; any other error means the current load
+// job failed and the stream must be aborted.
+func (s *loadStream) Write(row map[string]any) error {
+	line, err := json.Marshal(row)
+	if err != nil {
+		return &rowEncodeError{err: err}
+	}
+	line = append(line, '\n')
+
+	if !s.active {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+
+	if s.mode == segmentUpload {
+		if _, err := s.pw.Write(line); err != nil {
+			return fmt.Errorf("failed to stream row to BigQuery load job: %w", err)
+		}
+	}
+	s.jobRows++
+	s.jobBytes += int64(len(line))
+	s.lastRow = row
+
+	if (s.maxRows > 0 && s.jobRows >= s.maxRows) || (s.maxBytes > 0 && s.jobBytes >= s.maxBytes) {
+		return s.finish()
+	}
+	return nil
+}
+
+// Close completes the current load job, if any, and waits for it to succeed.
+func (s *loadStream) Close() error {
+	if !s.active {
+		return nil
+	}
+	return s.finish()
+}
+
+// Abort cancels the current load job, if any, so that none of its rows are committed.
+// A load job of a previous attempt that is already running is left alone.
+func (s *loadStream) Abort(cause error) {
+	if !s.active {
+		return
+	}
+	if s.mode == segmentUpload {
+		_ = s.pw.CloseWithError(cause)
+		<-s.done
+	}
+	s.reset()
+}
+
+// RowsLoaded returns the number of rows committed by completed load jobs.
+func (s *loadStream) RowsLoaded() int64 {
+	return s.rowsDone
+}
+
+// Jobs returns the number of completed load jobs.
+func (s *loadStream) Jobs() int {
+	return s.jobs
+}
+
+// start prepares the next segment: it resolves what to do with the segment's deterministic job ID,
+// if any, and launches a load job that reads from a new pipe until the pipe is closed.
+func (s *loadStream) start() error {
+	s.mode = segmentUpload
+	s.jobID = ""
+	if s.jobIDPrefix != "" {
+		jobID, existing, err := s.resolveSegmentJob()
+		if err != nil {
+			return err
+		}
+		s.jobID = jobID
+		if existing != nil {
+			s.attached = existing
+			s.mode = segmentAttached
+			if existing.LastStatus().Done() {
+				s.mode = segmentCommitted
+			}
+			s.logger.Info("Load job already submitted by a previous attempt, not resubmitting",
+				zap.String("job_id", jobID),
+				zap.Bool("completed", s.mode == segmentCommitted))
+		}
+	}
+
+	s.active = true
+	s.jobRows = 0
+	s.jobBytes = 0
+	if s.mode != segmentUpload {
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+
+	source := bigquery.NewReaderSource(pr)
+	source.SourceFormat = bigquery.JSON
+
+	loader := s.client.Dataset(s.datasetID).Table(s.table).LoaderFrom(source)
+	loader.JobID = s.jobID
+	loader.Location = s.client.Location
+	if s.truncateFirst && s.seq == 0 {
+		loader.WriteDisposition = bigquery.WriteTruncate
+	} else {
+		loader.WriteDisposition = bigquery.WriteAppend
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		err := runLoadJob(s.ctx, loader)
+		// Unblock the writer if the job failed before consuming the whole stream.
+		if err != nil {
+			_ = pr.CloseWithError(err)
+		} else {
+			_ = pr.Close()
+		}
+		done <- err
+	}()
+
+	s.pw = pw
+	s.done = done
+	return nil
+}
+
+// resolveSegmentJob looks up the attempts of the current segment's load job. It returns the job of
+// an attempt that succeeded or is still running, or otherwise the ID to submit the next attempt with.
+func (s *loadStream) resolveSegmentJob() (string, *bigquery.Job, error) {
+	return resolveJobAttempts(s.ctx, s.client, func(attempt int) string {
+		return segmentJobID(s.jobIDPrefix, s.seq, attempt)
+	})
+}
+
+// resolveJobAttempts looks up the attempts of a job with deterministic attempt IDs. It returns the job
+// of an attempt that succeeded or is still running, or otherwise the ID to submit the next attempt with.
+func resolveJobAttempts(ctx context.Context, client *bigquery.Client, attemptID func(attempt int) string) (string, *bigquery.Job, error) {
+	for attempt := 1; ; attempt++ {
+		jobID := attemptID(attempt)
+		bqJob, err := client.JobFromIDLocation(ctx, jobID, client.Location)
+		if err != nil {
+			if isNotFoundError(err) {
+				return jobID, nil, nil
+			}
+			return "", nil, fmt.Errorf("failed to look up BigQuery job %s: %w", jobID, err)
+		}
+
+		status := bqJob.LastStatus()
+		if status != nil && status.Done() && status.Err() != nil {
+			// This attempt failed without writing anything; the next attempt gets a new ID.
+			continue
+		}
+		return jobID, bqJob, nil
+	}
+}
+
+// finish completes the current segment and waits for its load job to succeed.
+func (s *loadStream) finish() error {
+	var err error
+	switch s.mode {
+	case segmentUpload:
+		_ = s.pw.Close()
+		if s.sealed != nil {
+			s.sealed(s.seq, s.jobID, s.jobRows, s.lastRow)
+		}
+		err = <-s.done
+	case segmentAttached:
+		err = waitForLoadJob(s.ctx, s.attached)
+	}
+	if err != nil {
+		s.reset()
+		return err
+	}
+
+	s.jobs++
+	s.rowsDone += s.jobRows
+	s.bytesDone += s.jobBytes
+
+	s.logger.Info("Load job completed",
+		zap.String("load_table", s.table),
+		zap.String("job_id", s.jobID),
+		zap.Int("load_job", s.jobs),
+		zap.Bool("resumed", s.mode != segmentUpload),
+		zap.Int64("rows", s.jobRows),
+		zap.Int64("bytes", s.jobBytes),
+		zap.Int64("rows_loaded_total", s.rowsDone),
+	)
+
+	s.seq++
+	lastRow := s.lastRow
+	s.reset()
+
+	if s.checkpoint != nil {
+		if err := s.checkpoint(s.jobs, s.rowsDone, lastRow); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reset clears the state of the current segment.
+func (s *loadStream) reset() {
+	s.active = false
+	s.pw = nil
+	s.done = nil
+	s.attached = nil
+	s.lastRow = nil
+}
+
+// loadJobIDPrefix returns the prefix of the deterministic load job IDs of a table's load table
+// within a run. Chunks of the table append their chunk number.
+func loadJobIDPrefix(runID, databaseName, loadTable string) string {
+	return sanitizeJobID(fmt.Sprintf("datasync_%s_%s_%s", runID, databaseName, loadTable))
+}
+
+// segmentJobID returns the deterministic job ID of one attempt of one load job segment.
+func segmentJobID(prefix string, seq, attempt int) string {
+	return fmt.Sprintf("%s_%05d_a%d", prefix, seq, attempt)
+}
+
+var invalidJobIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// sanitizeJobID replaces characters that are not allowed in BigQuery job IDs.
+func sanitizeJobID(id string) string {
+	return invalidJobIDChars.ReplaceAllString(id, "_")
+}
+
+// runLoadJob submits a load job, which uploads its streaming source, and waits for it to complete.
+func runLoadJob(ctx context.Context, loader *bigquery.Loader) error {
+	bqJob, err := loader.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery load job: %w", err)
+	}
+	return waitForLoadJob(ctx, bqJob)
+}
+
+// waitForLoadJob waits for a load job to complete and returns its error, if any.
+func waitForLoadJob(ctx context.Context, bqJob *bigquery.Job) error {
+	status, err := bqJob.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for BigQuery job %s: %w", bqJob.ID(), err)
+	}
+
+	if stErr := status.Err(); stErr != nil {
+		return fmt.Errorf("BigQuery load job %s failed: %w.%s", bqJob.ID(), stErr, formatBigQueryStatusErrors(status))
+	}
+	return nil
+}
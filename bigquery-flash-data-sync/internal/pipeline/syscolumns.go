@@ -0,0 +1,111 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+)
+
+// System columns the pipeline adds to target tables next to the source columns.
+const (
+	rowHashColumn   = "_row_hash"   // Hash of the row's source values (CHANGE_DETECTION=hash, WRITE_MODE=scd2)
+	isDeletedColumn = "_is_deleted" // TRUE once the row's key has disappeared from the source
+	deletedAtColumn = "_deleted_at" // When the row was found deleted
+	validFromColumn = "_valid_from" // When the row version became current (WRITE_MODE=scd2)
+	validToColumn   = "_valid_to"   // When the row version was superseded or deleted, NULL while current
+	isCurrentColumn = "_is_current" // TRUE for the current version of a row
+
+	runIDColumn          = "_run_id"          // Run that took the snapshot (WRITE_MODE=snapshot)
+	syncedAtColumn       = "_synced_at"       // When the snapshot was taken; the target is partitioned by its date
+	sourceDatabaseColumn = "_source_database" // Configured name of the source database
+)
+
+// rowSchema returns the schema of the rows extracted for a table: the source schema followed by the
+// system columns computed for every row.
+func rowSchema(schema bigquery.Schema, tableConfig *model.TableConfig) bigquery.Schema {
+	if tableConfig.HashesRows() {
+		schema = appendFields(schema, &bigquery.FieldSchema{Name: rowHashColumn, Type: bigquery.StringFieldType})
+	}
+	if tableConfig.TakesSnapshots() {
+		schema = appendFields(schema,
+			&bigquery.FieldSchema{Name: runIDColumn, Type: bigquery.StringFieldType},
+			&bigquery.FieldSchema{Name: syncedAtColumn, Type: bigquery.TimestampFieldType},
+			&bigquery.FieldSchema{Name: sourceDatabaseColumn, Type: bigquery.StringFieldType},
+		)
+	}
+	return schema
+}
+
+// targetSchema returns the schema of the target table of a table: the extracted row schema followed
+// by the system columns the pipeline maintains in the target. System columns are nullable so that
+// rows loaded without them are valid.
+func targetSchema(schema bigquery.Schema, tableConfig *model.TableConfig) bigquery.Schema {
+	schema = rowSchema(schema, tableConfig)
+	// History tables close the current version of a deleted row instead of flagging it.
+	var system bigquery.Schema
+	if tableConfig.KeepsHistory() {
+		system = append(system,
+			&bigquery.FieldSchema{Name: validFromColumn, Type: bigquery.TimestampFieldType},
+			&bigquery.FieldSchema{Name: validToColumn, Type: bigquery.TimestampFieldType},
+			&bigquery.FieldSchema{Name: isCurrentColumn, Type: bigquery.BooleanFieldType},
+		)
+	} else if tableConfig.DeleteDetection == model.DeleteDetectionFlag {
+		system = append(system,
+			&bigquery.FieldSchema{Name: isDeletedColumn, Type: bigquery.BooleanFieldType},
+			&bigquery.FieldSchema{Name: deletedAtColumn, Type: bigquery.TimestampFieldType},
+		)
+	}
+	return appendFields(schema, system...)
+}
+
+// appendFields returns a copy of schema with fields appended.
+func appendFields(schema bigquery.Schema, fields ...*bigquery.FieldSchema) bigquery.Schema {
+	if len(fields) == 0 {
+		return schema
+	}
+	out := make(bigquery.Schema, 0, len(schema)+len(fields))
+	out = append(out, schema...)
+	return append(out, fields...)
+}
+
+// withRowHash adds the _row_hash column to a parsed row.
+func withRowHash(row *model.DynamicRow) *model.DynamicRow {
+	hash := row.Hash()
+	row.ColumnNames = append(row.ColumnNames, rowHashColumn)
+	row.Values = append(row.Values, hash)
+	return row
+}
+
+// targetPartitioning returns the partitioning the target table of a table is created with, or nil
+// when it is not partitioned. Snapshot tables are partitioned by the date of _synced_at, so that a
+// query for one snapshot only scans its day.
+func targetPartitioning(tableConfig *model.TableConfig) *bigquery.TimePartitioning {
+	if !tableConfig.TakesSnapshots() {
+		return nil
+	}
+	return &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Field: syncedAtColumn}
+}
+
+// withSnapshotColumns adds the _run_id, _synced_at and _source_database columns to a parsed row.
+func withSnapshotColumns(row *model.DynamicRow, runID string, syncedAt time.Time, database string) *model.DynamicRow {
+	row.ColumnNames = append(row.ColumnNames, runIDColumn, syncedAtColumn, sourceDatabaseColumn)
+	row.Values = append(row.Values, runID, syncedAt.UTC().Format(time.RFC3339Nano), database)
+	return row
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// watermarkWindow bounds the rows read by an incremental sync.
+// Rows are selected with TimestampColumn > From (only when HasFrom) AND TimestampColumn <= To.
+type watermarkWindow struct {
+	Previous time.Time // Stored high-water mark (zero when HasFrom is false)
+	From     time.Time // Previous minus the configured overlap
+	HasFrom  bool
+	To       time.Time // Maximum timestamp in the source when the run started
+}
+
+// NextWatermark returns the high-water mark to store once every load for the window succeeded.
+// The mark never moves backwards, even if the source maximum went down.
+func (w *watermarkWindow) NextWatermark() time.Time {
+	if w.HasFrom && w.Previous.After(w.To) {
+		return w.Previous
+	}
+	return w.To
+}
+
+// resolveWatermarkWindow determines the incremental read window for a table from its stored
+// high-water mark and the current maximum of its timestamp column. The upper bound is captured
+// up front so rows written while the sync runs are picked up by the next run instead of being
+// skipped. Returns nil when the timestamp column has no values (empty source table). The maximum
+// is read from the rows the table's custom QUERY and WHERE select, with :watermark bound already.
+func resolveWatermarkWindow(ctx context.Context, bqClient *bigquery.Client, cfg *model.Config, source *sourceReader, dbConfig *model.DatabaseConfig, tableConfig *model.TableConfig, runStartedAt time.Time, logger *zap.Logger) (*watermarkWindow, error) {
+	if err := validateSQLIdentifier(tableConfig.TimestampColumn); err != nil {
+		return nil, fmt.Errorf("invalid timestamp column: %w", err)
+	}
+
+	previous, found, err := loadWatermark(ctx, bqClient, cfg, dbConfig.Name, tableConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	window := &watermarkWindow{}
+	if found {
+		window.Previous = previous
+		window.From = previous.Add(-tableConfig.WatermarkOverlap)
+		window.HasFrom = true
+	}
+
+	from, conditions, args, err := sourceSelection(dbConfig, tableConfig, sourceParams{Window: window, RunStartedAt: runStartedAt}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var maxTS sql.NullTime
+	maxQuery := fmt.Sprintf("SELECT MAX(%s) FROM %s", tableConfig.TimestampColumn, from)
+	if len(conditions) > 0 {
+		maxQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if err := source.queryRow(ctx, maxQuery, args, &maxTS); err != nil {
+		return nil, fmt.Errorf("failed to read maximum of timestamp column %s: %w", tableConfig.TimestampColumn, err)
+	}
+	if !maxTS.Valid {
+		return nil, nil
+	}
+	window.To = maxTS.Time
+
+	logger.Info("Resolved incremental watermark window",
+		zap.Bool("has_previous_watermark", found),
+		zap.Time("previous_watermark", window.Previous),
+		zap.Time("window_from", window.From),
+		zap.Time("window_to", window.To),
+		zap.Duration("overlap", tableConfig.WatermarkOverlap),
+	)
+
+	return window, nil
+}
+
+// watermarkCondition returns the SQL condition and arguments restricting a source query to the window.
+// Placeholders are numbered from argOffset+1 so the condition can be combined with other filters.
+func watermarkCondition(dbType, timestampColumn string, window *watermarkWindow, argOffset int) (string, []any) {
+	if !window.HasFrom {
+		return fmt.Sprintf("%s <= %s", timestampColumn, sqlPlaceholder(dbType, argOffset+1)), []any{window.To}
+	}
+	return fmt.Sprintf("%s > %s AND %s <= %s",
+			timestampColumn, sqlPlaceholder(dbType, argOffset+1),
+			timestampColumn, sqlPlaceholder(dbType, argOffset+2)),
+		[]any{window.From, window.To}
+}
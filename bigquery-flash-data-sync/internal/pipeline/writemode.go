@@ -0,0 +1,285 @@
+// Copyright (c) 2025 WSO2 LLC. (https://www.wso2.com).
+//
+// WSO2 LLC. licenses this file to you under the Apache License,
+// Version 2.0 (the "License"); you may not use this file except
+// in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/wso2-open-operations/common-tools/bigquery-flash-data-sync/internal/model"
+	"go.uber.org/zap"
+)
+
+// stagingTableTTL is how long a staging table lives if the run dies before dropping it.
+const stagingTableTTL = 24 * time.Hour
+
+// stagingTableName returns the name of the per-run staging table of the given kind for a target table.
+func stagingTableName(target, kind, runID string) string {
+	return fmt.Sprintf("%s__%s_%s", target, kind, runID)
+}
+
+// createStagingTable creates a staging table with the given schema that expires automatically.
+// An existing table with the same name is reused; the first load job into it truncates it.
+func createStagingTable(ctx context.Context, client *bigquery.Client, datasetID, name string, schema bigquery.Schema, logger *zap.Logger) error {
+	if err := validateBigQueryIdentifier(name, "Staging table name"); err != nil {
+		return err
+	}
+
+	err := client.Dataset(datasetID).Table(name).Create(ctx, &bigquery.TableMetadata{
+		Name:           name,
+		Schema:         schema,
+		ExpirationTime: time.Now().Add(stagingTableTTL),
+	})
+	if err != nil && !isAlreadyExistsError(err) {
+		return fmt.Errorf("failed to create staging table '%s': %w", name, err)
+	}
+
+	logger.Debug("Staging table ready",
+		zap.String("dataset", datasetID),
+		zap.String("staging_table", name))
+	return nil
+}
+
+// dropStagingTable deletes a staging table. Failures are only logged because the
+// table expires on its own after stagingTableTTL.
+func dropStagingTable(ctx context.Context, client *bigquery.Client, datasetID, name string, logger *zap.Logger) {
+	if err := client.Dataset(datasetID).Table(name).Delete(ctx); err != nil && !isNotFoundError(err) {
+		logger.Warn("Failed to drop staging table, it will expire automatically",
+			zap.String("staging_table", name),
+			zap.Duration("ttl", stagingTableTTL),
+			zap.Error(err))
+	}
+}
+
+// stagingKindFor returns the kind of staging table a write mode loads into, or "" when
+// rows are loaded directly into the target table.
+func stagingKindFor(writeMode string) string {
+	switch writeMode {
+	case model.WriteModeMerge, model.WriteModeSCD2:
+		return "staging"
+	case model.WriteModeTruncate:
+		return "shadow"
+	case model.WriteModeSnapshot:
+		return "snapshot"
+	default:
+		return ""
+	}
+}
+
+// replaceTargetFromStaging atomically replaces the contents of the target table with the
+// contents of a fully loaded shadow table using a single WRITE_TRUNCATE copy job. Readers
+// of the target see either the previous data or the complete new data, never a partial load.
+func replaceTargetFromStaging(ctx context.Context, client *bigquery.Client, datasetID, staging, target string, logger *zap.Logger) error {
+	logger.Debug("Replacing target table from shadow table",
+		zap.String("shadow_table", staging))
+	return copyStagingToTarget(ctx, client, datasetID, staging, target, bigquery.WriteTruncate)
+}
+
+// appendStagingToTarget atomically appends every row of a fully loaded staging table to the
+// target table with a single INSERT statement, which leaves columns the target kept after the
+// source dropped them NULL. The statement's query job gets a deterministic ID derived from
+// jobIDPrefix, and an attempt that succeeded or is still running is waited for instead of
+// submitting another one, so a retry never appends the rows twice.
+func appendStagingToTarget(ctx context.Context, client *bigquery.Client, cfg *model.Config, staging, target string, schema bigquery.Schema, jobIDPrefix string, logger *zap.Logger) error {
+	jobID, existing, err := resolveJobAttempts(ctx, client, func(attempt int) string {
+		return fmt.Sprintf("%s_append_a%d", jobIDPrefix, attempt)
+	})
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		logger.Info("Waiting for the query job of an earlier attempt to append the staging table",
+			zap.String("staging_table", staging),
+			zap.String("job_id", existing.ID()))
+		err = waitForBigQueryStatement(ctx, existing)
+	} else {
+		sql := insertFromStagingStatement(cfg, staging, target, schema)
+		logger.Debug("Appending staging table to target table",
+			zap.String("staging_table", staging),
+			zap.String("job_id", jobID),
+			zap.String("statement", sql))
+		err = runBigQueryStatementWithJobID(ctx, client, sql, nil, jobID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append staging table '%s' to '%s': %w", staging, target, err)
+	}
+	return nil
+}
+
+// insertFromStagingStatement builds an INSERT statement that appends the columns of schema from a
+// staging table to the target table.
+func insertFromStagingStatement(cfg *model.Config, staging, target string, schema bigquery.Schema) string {
+	columns := make([]string, 0, len(schema))
+	for _, field := range schema {
+		columns = append(columns, quoteBigQueryIdentifier(field.Name))
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s)\nSELECT %s FROM %s",
+		bigQueryTableRef(cfg, target), strings.Join(columns, ", "), strings.Join(columns, ", "), bigQueryTableRef(cfg, staging))
+}
+
+// appendSnapshotFromStaging atomically appends a fully loaded snapshot table to the partitioned
+// target table. An INSERT statement is used instead of a copy job so that the rows are placed in
+// the target's partitions, and so that columns the target kept after the source dropped them are
+// left NULL. Nothing is inserted if the target already holds the run's snapshot of the database, so
+// the statement can be retried after an attempt whose outcome is unknown. The check only reads the
+// partitions from since, the earliest time a row of the run's snapshot can have been taken at.
+func appendSnapshotFromStaging(ctx context.Context, client *bigquery.Client, cfg *model.Config, staging, target string, schema bigquery.Schema, runID, database string, since time.Time, logger *zap.Logger) error {
+	sql := insertFromStagingStatement(cfg, staging, target, schema) + fmt.Sprintf(
+		"\nWHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s >= @taken_at AND %s = @run_id AND %s = @source_database)",
+		bigQueryTableRef(cfg, target), quoteBigQueryIdentifier(syncedAtColumn),
+		quoteBigQueryIdentifier(runIDColumn), quoteBigQueryIdentifier(sourceDatabaseColumn))
+	params := []bigquery.QueryParameter{
+		{Name: "taken_at", Value: since},
+		{Name: "run_id", Value: runID},
+		{Name: "source_database", Value: database},
+	}
+
+	logger.Debug("Appending snapshot table to target table",
+		zap.String("snapshot_table", staging),
+		zap.String("statement", sql))
+
+	if err := runBigQueryStatement(ctx, client, sql, params); err != nil {
+		return fmt.Errorf("failed to append snapshot table '%s' to '%s': %w", staging, target, err)
+	}
+	return nil
+}
+
+// copyStagingToTarget copies a staging table into the target table with the given write disposition.
+func copyStagingToTarget(ctx context.Context, client *bigquery.Client, datasetID, staging, target string, disposition bigquery.TableWriteDisposition) error {
+	dataset := client.Dataset(datasetID)
+	copier := dataset.Table(target).CopierFrom(dataset.Table(staging))
+	copier.WriteDisposition = disposition
+	copier.CreateDisposition = bigquery.CreateIfNeeded
+
+	bqJob, err := copier.Run(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create BigQuery copy job: %w", err)
+	}
+
+	status, err := bqJob.Wait(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for BigQuery job: %w", err)
+	}
+
+	if stErr := status.Err(); stErr != nil {
+		return fmt.Errorf("BigQuery copy job failed: %w.%s", stErr, formatBigQueryStatusErrors(status))
+	}
+	return nil
+}
+
+// mergeStagingIntoTarget upserts the rows of a staging table into the target table on the
+// given key columns: matching rows are updated, new rows are inserted. If the staging table
+// holds several rows for a key, the one ordered first by orderBy wins.
+func mergeStagingIntoTarget(ctx context.Context, client *bigquery.Client, cfg *model.Config, staging, target string, schema bigquery.Schema, keys []string, orderBy string, logger *zap.Logger) error {
+	sql, err := buildMergeStatement(cfg, staging, target, schema, keys, orderBy, "")
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Merging staging table into target",
+		zap.String("staging_table", staging),
+		zap.Strings("primary_key", keys),
+		zap.String("statement", sql))
+
+	if err := runBigQueryStatement(ctx, client, sql, nil); err != nil {
+		return fmt.Errorf("failed to merge staging table '%s' into '%s': %w", staging, target, err)
+	}
+	return nil
+}
+
+// buildMergeStatement builds the BigQuery MERGE statement used by mergeStagingIntoTarget. When opColumn
+// is set, the winning staged row of a key is a change record and deletes the target row if its
+// operation is a delete. When the schema has a _row_hash column, matched rows are only updated if
+// their hash differs.
+func buildMergeStatement(cfg *model.Config, staging, target string, schema bigquery.Schema, keys []string, orderBy, opColumn string) (string, error) {
+	if len(keys) == 0 {
+		return "", fmt.Errorf("merge requires at least one primary key column")
+	}
+
+	fields := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		fields[field.Name] = true
+	}
+
+	isKey := make(map[string]bool, len(keys))
+	quotedKeys := make([]string, 0, len(keys))
+	joins := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !fields[key] {
+			return "", fmt.Errorf("primary key column %q not found in source schema", key)
+		}
+		isKey[key] = true
+		quotedKeys = append(quotedKeys, quoteBigQueryIdentifier(key))
+		joins = append(joins, fmt.Sprintf("T.%s = S.%s", quoteBigQueryIdentifier(key), quoteBigQueryIdentifier(key)))
+	}
+
+	matched := "WHEN MATCHED THEN"
+	var columns, values, updates []string
+	for _, field := range schema {
+		if field.Name == rowHashColumn {
+			hash := quoteBigQueryIdentifier(rowHashColumn)
+			matched = fmt.Sprintf("WHEN MATCHED AND T.%s IS DISTINCT FROM S.%s THEN", hash, hash)
+		}
+		col := quoteBigQueryIdentifier(field.Name)
+		columns = append(columns, col)
+		values = append(values, "S."+col)
+		if !isKey[field.Name] {
+			updates = append(updates, fmt.Sprintf("%s = S.%s", col, col))
+		}
+	}
+
+	if orderBy == "" {
+		orderBy = strings.Join(quotedKeys, ", ")
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "MERGE %s T\n", bigQueryTableRef(cfg, target))
+	fmt.Fprintf(&b, "USING (\n  SELECT * FROM %s\n  WHERE TRUE\n  QUALIFY ROW_NUMBER() OVER (PARTITION BY %s ORDER BY %s) = 1\n) S\n",
+		bigQueryTableRef(cfg, staging), strings.Join(quotedKeys, ", "), orderBy)
+	fmt.Fprintf(&b, "ON %s\n", strings.Join(joins, " AND "))
+	notMatched := "WHEN NOT MATCHED THEN"
+	if opColumn != "" {
+		isDelete := fmt.Sprintf("S.%s = '%s'", quoteBigQueryIdentifier(opColumn), changeDelete)
+		fmt.Fprintf(&b, "WHEN MATCHED AND %s THEN\n  DELETE\n", isDelete)
+		notMatched = fmt.Sprintf("WHEN NOT MATCHED AND NOT %s THEN", isDelete)
+	}
+	if len(updates) > 0 {
+		fmt.Fprintf(&b, "%s\n  UPDATE SET %s\n", matched, strings.Join(updates, ", "))
+	}
+	fmt.Fprintf(&b, "%s\n  INSERT (%s) VALUES (%s)", notMatched, strings.Join(columns, ", "), strings.Join(values, ", "))
+
+	return b.String(), nil
+}
+
+// mergeOrderBy returns the ORDER BY expression used to pick the newest staged row per key.
+// The timestamp column is used when it is part of the schema; otherwise the order is arbitrary.
+func mergeOrderBy(schema bigquery.Schema, timestampColumn string) string {
+	for _, field := range schema {
+		if timestampColumn != "" && field.Name == timestampColumn {
+			return quoteBigQueryIdentifier(timestampColumn) + " DESC"
+		}
+	}
+	return ""
+}
+
+// quoteBigQueryIdentifier quotes a column or table identifier for use in BigQuery SQL.
+func quoteBigQueryIdentifier(name string) string {
+	return "`" + name + "`"
+}